@@ -0,0 +1,128 @@
+package benchmark
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// BalancePolicy chooses which endpoint a Balancer sends each request to
+type BalancePolicy int
+
+const (
+	// RoundRobin sends each request to the next endpoint in turn, like the gRPC round_robin policy
+	RoundRobin BalancePolicy = iota
+	// LeastOutstanding sends each request to the endpoint with the fewest requests in flight.
+	// Ties are broken in round-robin order.
+	LeastOutstanding
+)
+
+func (p BalancePolicy) String() string {
+	switch p {
+	case RoundRobin:
+		return "RoundRobin"
+	case LeastOutstanding:
+		return "LeastOutstanding"
+	}
+	return fmt.Sprintf("BalancePolicy(%d)", int(p))
+}
+
+// Balancer is a http.RoundTripper which spreads requests across several endpoints. The scheme
+// and host of each request are replaced with those of the chosen endpoint, so HTTPClient can
+// be given any endpoint.
+type Balancer struct {
+	transport http.RoundTripper
+	policy    BalancePolicy
+	endpoints []*endpoint
+	next      atomic.Uint64
+}
+
+type endpoint struct {
+	url         *url.URL
+	outstanding atomic.Int64
+}
+
+// NewBalancer returns a Balancer which sends requests to the endpoints through the transport
+func NewBalancer(transport http.RoundTripper, policy BalancePolicy, endpoints ...string) (*Balancer, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("at least one endpoint is required")
+	}
+	b := &Balancer{transport: transport, policy: policy}
+	for _, e := range endpoints {
+		u, err := url.Parse(e)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing endpoint '%s': %w", e, err)
+		}
+		b.endpoints = append(b.endpoints, &endpoint{url: u})
+	}
+	return b, nil
+}
+
+// Outstanding returns the number of requests in flight to each endpoint
+func (b *Balancer) Outstanding() []int64 {
+	out := make([]int64, len(b.endpoints))
+	for i, e := range b.endpoints {
+		out[i] = e.outstanding.Load()
+	}
+	return out
+}
+
+func (b *Balancer) RoundTrip(r *http.Request) (*http.Response, error) {
+	e := b.pick()
+
+	// A RoundTripper must not modify the request
+	req := *r
+	u := *r.URL
+	u.Scheme, u.Host = e.url.Scheme, e.url.Host
+	req.URL, req.Host = &u, ""
+
+	e.outstanding.Add(1)
+	resp, err := b.transport.RoundTrip(&req)
+	if err != nil {
+		e.outstanding.Add(-1)
+		return nil, err
+	}
+	// The request is outstanding until the client is done with the response
+	resp.Body = &doneBody{ReadCloser: resp.Body, done: func() { e.outstanding.Add(-1) }}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the transport, see http.Client.CloseIdleConnections()
+func (b *Balancer) CloseIdleConnections() {
+	if c, ok := b.transport.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+func (b *Balancer) pick() *endpoint {
+	start := int((b.next.Add(1) - 1) % uint64(len(b.endpoints)))
+	if b.policy == RoundRobin {
+		return b.endpoints[start]
+	}
+
+	best := b.endpoints[start]
+	for i := 1; i < len(b.endpoints); i++ {
+		e := b.endpoints[(start+i)%len(b.endpoints)]
+		if e.outstanding.Load() < best.outstanding.Load() {
+			best = e
+		}
+	}
+	return best
+}
+
+// doneBody calls done once when the body is closed
+type doneBody struct {
+	io.ReadCloser
+	closed atomic.Bool
+	done   func()
+}
+
+func (b *doneBody) Close() error {
+	if b.closed.CompareAndSwap(false, true) {
+		b.done()
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,284 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+var (
+	balanceInstances = flag.Int("balance-instances", 3, "number of servers BenchmarkBalance spreads calls across")
+	balanceSlow      = flag.Duration("balance-slow", 5*time.Millisecond, "latency added to every call made to "+
+		"one of the servers in the OneSlow scenario of BenchmarkBalance")
+	balanceParallelism = flag.Int("balance-parallelism", 16, "concurrent callers per GOMAXPROCS in BenchmarkBalance")
+)
+
+func TestBalancer(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	release := make(chan struct{})
+	var servers []string
+	for i := 0; i < 3; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[r.Host]++
+			mu.Unlock()
+			if r.URL.Path == "/block" {
+				<-release
+			}
+		}))
+		defer srv.Close()
+		servers = append(servers, srv.URL)
+	}
+	host := func(i int) string { return strings.TrimPrefix(servers[i], "http://") }
+
+	get := func(t *testing.T, client *http.Client, path string) {
+		t.Helper()
+		resp, err := client.Get("http://balancer" + path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = resp.Body.Close()
+	}
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		lb, err := benchmark.NewBalancer(http.DefaultTransport, benchmark.RoundRobin, servers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: lb}
+		clear(hits)
+		for i := 0; i < 30; i++ {
+			get(t, client, "/")
+		}
+		for i := range servers {
+			if hits[host(i)] != 10 {
+				t.Errorf("expected 10 requests to endpoint %d; got '%d'", i, hits[host(i)])
+			}
+		}
+	})
+
+	t.Run("LeastOutstanding", func(t *testing.T) {
+		lb, err := benchmark.NewBalancer(http.DefaultTransport, benchmark.LeastOutstanding, servers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: lb}
+		clear(hits)
+
+		// The first request is sent to the first endpoint, which holds it until released
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, "/block")
+		}()
+		for lb.Outstanding()[0] != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		for i := 0; i < 20; i++ {
+			get(t, client, "/")
+		}
+		close(release)
+		wg.Wait()
+
+		if hits[host(0)] != 1 {
+			t.Errorf("expected only the blocked request to reach the busy endpoint; got '%d'", hits[host(0)])
+		}
+		if hits[host(1)] == 0 || hits[host(2)] == 0 || hits[host(1)]+hits[host(2)] != 20 {
+			t.Errorf("expected the idle endpoints to share 20 requests; got '%d' and '%d'", hits[host(1)], hits[host(2)])
+		}
+		for i, n := range lb.Outstanding() {
+			if n != 0 {
+				t.Errorf("expected no outstanding requests to endpoint %d; got '%d'", i, n)
+			}
+		}
+	})
+}
+
+// balanceClients create a client for each transport which balances calls across the addresses
+var balanceClients = map[string]func(addrs []string, policy benchmark.BalancePolicy) (
+	getFeature func(context.Context, *pb.Point) error, closer func(), err error){
+	"GRPC":  balanceGRPC,
+	"HTTP2": balanceH2C,
+	"HTTP1": balanceHTTP1,
+}
+
+// BenchmarkBalance spreads concurrent calls across several servers, and measures how each client
+// copes when one of the servers slows down. gRPC uses its resolver and the round_robin policy, which
+// is the only policy grpc-go provides which spreads calls; HTTPClient uses a Balancer.
+func BenchmarkBalance(b *testing.B) {
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTP1"} {
+		policies := []benchmark.BalancePolicy{benchmark.RoundRobin, benchmark.LeastOutstanding}
+		if transport == "GRPC" {
+			policies = policies[:1]
+		}
+		b.Run(transport, func(b *testing.B) {
+			svcs := make([]*server.RouteGuideService, *balanceInstances)
+			conns := make([]*benchmark.ConnStats, *balanceInstances)
+			addrs := make([]string, *balanceInstances)
+			for i := range svcs {
+				l, err := net.Listen("tcp", "localhost:0")
+				if err != nil {
+					b.Fatal(err)
+				}
+				svcs[i], conns[i], addrs[i] = server.NewRouteGuideServer(), &benchmark.ConnStats{}, l.Addr().String()
+				defer servers[transport](conns[i].Listener(l), svcs[i], nil)()
+			}
+
+			for _, policy := range policies {
+				b.Run(policy.String(), func(b *testing.B) {
+					getFeature, closer, err := balanceClients[transport](addrs, policy)
+					if err != nil {
+						b.Fatal(err)
+					}
+					defer closer()
+
+					for _, slow := range []time.Duration{0, *balanceSlow} {
+						name := "Even"
+						if slow != 0 {
+							name = "OneSlow"
+						}
+						b.Run(name, func(b *testing.B) {
+							svcs[0].SetLatency(slow)
+							defer svcs[0].SetLatency(0)
+							runBalance(b, conns, getFeature)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// runBalance calls getFeature from many goroutines and reports the latency percentiles and the
+// share of calls made to the first, possibly slow, server
+func runBalance(b *testing.B, conns []*benchmark.ConnStats, getFeature func(context.Context, *pb.Point) error) {
+	ctx := context.Background()
+	// Connect to every server before the timer starts
+	for i := 0; i < 2*len(conns); i++ {
+		if err := getFeature(ctx, knownPoint); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	before := make([]benchmark.ConnCounts, len(conns))
+	for i, c := range conns {
+		before[i] = c.Counts()
+	}
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	b.SetParallelism(*balanceParallelism)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(p *testing.PB) {
+		var local []time.Duration
+		for p.Next() {
+			start := time.Now()
+			if err := getFeature(ctx, knownPoint); err != nil {
+				b.Error(err)
+				return
+			}
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+
+	// Every request is the same size, so the bytes each server read are proportional to its calls
+	var first, total int64
+	for i, c := range conns {
+		n := c.Counts().Sub(before[i]).BytesRead
+		if i == 0 {
+			first = n
+		}
+		total += n
+	}
+	if total != 0 {
+		b.ReportMetric(float64(first)/float64(total), "first-share")
+	}
+	p := benchmark.NewPercentiles(latencies)
+	b.ReportMetric(float64(p.P50), "p50-ns")
+	b.ReportMetric(float64(p.P99), "p99-ns")
+}
+
+func balanceGRPC(addrs []string, _ benchmark.BalancePolicy) (func(context.Context, *pb.Point) error, func(), error) {
+	r := manual.NewBuilderWithScheme("balance")
+	var state resolver.State
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	r.InitialState(state)
+
+	conn, err := grpc.Dial(r.Scheme()+":///routeguide", grpc.WithResolvers(r),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock(),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`))
+	if err != nil {
+		return nil, nil, err
+	}
+	client := pb.NewRouteGuideClient(conn)
+	return func(ctx context.Context, point *pb.Point) error {
+		_, err := client.GetFeature(ctx, point)
+		return err
+	}, func() { _ = conn.Close() }, nil
+}
+
+func balanceH2C(addrs []string, policy benchmark.BalancePolicy) (func(context.Context, *pb.Point) error, func(), error) {
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return balanceHTTP(transport, addrs, policy)
+}
+
+func balanceHTTP1(addrs []string, policy benchmark.BalancePolicy) (func(context.Context, *pb.Point) error, func(), error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Keep a connection for every concurrent caller, rather than closing all but two after each burst.
+	// RunParallel() runs the parallelism times GOMAXPROCS callers, which may all call the same host.
+	callers := *balanceParallelism * runtime.GOMAXPROCS(0)
+	transport.MaxIdleConnsPerHost = callers
+	transport.MaxIdleConns = callers * len(addrs)
+	return balanceHTTP(transport, addrs, policy)
+}
+
+func balanceHTTP(transport http.RoundTripper, addrs []string, policy benchmark.BalancePolicy) (
+	func(context.Context, *pb.Point) error, func(), error) {
+	endpoints := make([]string, len(addrs))
+	for i, addr := range addrs {
+		endpoints[i] = "http://" + addr
+	}
+	lb, err := benchmark.NewBalancer(transport, policy, endpoints...)
+	if err != nil {
+		return nil, nil, err
+	}
+	hc := &http.Client{Transport: lb}
+	client := benchmark.NewClient(hc, endpoints[0])
+	return func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
+	}, hc.CloseIdleConnections, nil
+}
@@ -4,43 +4,78 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	hzclient "github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/duh-rpc/duh-go"
 	benchmark "github.com/duh-rpc/duh-go-benchmarks"
 	"github.com/duh-rpc/duh-go-benchmarks/server"
 	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"github.com/valyala/fasthttp"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+var (
+	resultsFile   = flag.String("results", "", "write structured benchmark results to this file")
+	resultsFormat = flag.String("results-format", "", "format of the -results file; 'json' or 'csv' "+
+		"(default is chosen by the file extension)")
+	profileDir = flag.String("profile-dir", "", "capture CPU, heap, mutex and block profiles for each "+
+		"transport and scenario into this directory")
+	serverProcess = flag.Bool("server-process", false, "run the server in a routeguide-server child process and "+
+		"report the CPU time and allocations of the server and client separately")
+	keyType = flag.String("key-type", string(benchmark.KeyTypeP256), "algorithm of the keys generated for "+
+		"BenchmarkHTTPS and BenchmarkGRPCTLS; one of P-256, P-384, P-521, Ed25519, RSA-2048 or RSA-4096")
+	tlsProfile = flag.String("tls-profile", string(benchmark.TLSProfileDefault), "TLS versions and cipher "+
+		"suites of BenchmarkHTTPS and BenchmarkGRPCTLS; one of Default, TLS1.3, TLS1.2-AES128-GCM, "+
+		"TLS1.2-AES256-GCM or TLS1.2-ChaCha20")
+	wire = flag.Bool("wire", false, "count the bytes and Read()/Write() calls on every connection and report "+
+		"them per call; counting adds to ns/op, and the connections of Hertz are never counted")
+	serverBin = flag.String("server-bin", "", "path to the routeguide-server binary spawned by the benchmarks "+
+		"(default is to build it from ./cmd/routeguide-server)")
+	latencyCalls = flag.Int("latency-calls", 1000, "number of calls timed one by one after each scenario to "+
+		"record the latency percentiles of the -results; the calls do not count towards ns/op")
+	recorder *benchmark.Recorder
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	recorder = benchmark.NewRecorder()
+	if *profileDir != "" {
+		benchmark.EnableProfiling()
+	}
+	code := m.Run()
+
+	removeServerBinary()
+	if *resultsFile != "" {
+		if err := recorder.WriteFile(*resultsFile, *resultsFormat); err != nil {
+			log.Printf("while writing results: %s", err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
 func BenchmarkGRPC(b *testing.B) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
 	const GRPCAddress = "localhost:9081"
-	listener, err := net.Listen("tcp", GRPCAddress)
-	if err != nil {
-		b.Fatalf("failed to listen: %v", err)
-	}
-	defer func() { _ = listener.Close() }()
-
-	grpcServer := grpc.NewServer()
-	go func() {
-		pb.RegisterRouteGuideServer(grpcServer, server.NewRouteGuideServer())
-		if err := grpcServer.Serve(listener); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				log.Fatal(err)
-			}
-		}
-	}()
+	side := startServer(b, GRPCAddress, nil)
+	defer side.Close()
 
 	// Wait for the server in the go routine to start
 	if err := WaitForConnect(ctx, GRPCAddress); err != nil {
@@ -49,54 +84,67 @@ func BenchmarkGRPC(b *testing.B) {
 
 	// To make it a fair comparison, and avoid any slow down when establishing a
 	// connection, We use `WithBlock()` to wait for a connection here before moving on with the test.
-	conn, err := grpc.Dial(GRPCAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	conns := newConnStats()
+	conn, err := grpc.Dial(GRPCAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return conns.DialContext(ctx, "tcp", addr)
+		}))
 	if err != nil {
 		b.Fatalf("fail to dial: %v", err)
 	}
 	defer func() { _ = conn.Close() }()
 	client := pb.NewRouteGuideClient(conn)
 
-	b.Run("grpc.GetFeature()", func(b *testing.B) {
-		for n := 0; n < b.N; n++ {
-			_, err := client.GetFeature(ctx, &pb.Point{Latitude: 409146138, Longitude: -746188906})
-			if err != nil {
-				b.Fatalf("client.GetFeature failed: %v", err)
-			}
-		}
+	runScenarios(b, "grpc", "grpc+HTTP/2.0", side, conns, func(ctx context.Context, point *pb.Point) error {
+		_, err := client.GetFeature(ctx, point)
+		return err
 	})
 	b.ReportAllocs()
-	grpcServer.GracefulStop()
 }
 
-func BenchmarkHTTP2(b *testing.B) {
+// BenchmarkGRPCTuned is BenchmarkGRPC with the server and client tuned by
+// benchmark.DefaultGRPCTuning, so the comparison is not only default gRPC versus tuned HTTP
+func BenchmarkGRPCTuned(b *testing.B) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
-	const HTTPAddress = "localhost:9080"
-	listener, err := net.Listen("tcp", HTTPAddress)
-	if err != nil {
+	const GRPCAddress = "localhost:9085"
+	side := startServer(b, GRPCAddress, nil)
+	defer side.Close()
+
+	// Wait for the server in the go routine to start
+	if err := WaitForConnect(ctx, GRPCAddress); err != nil {
 		b.Fatal(err)
 	}
-	defer func() { _ = listener.Close() }()
 
-	handler := benchmark.NewHTTPHandler(server.NewRouteGuideServer())
+	// See BenchmarkGRPC for why we use `WithBlock()`
+	conns := newConnStats()
+	opts := append(benchmark.DefaultGRPCTuning.DialOptions(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return conns.DialContext(ctx, "tcp", addr)
+		}))
+	conn, err := grpc.Dial(GRPCAddress, opts...)
+	if err != nil {
+		b.Fatalf("fail to dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := pb.NewRouteGuideClient(conn)
 
-	// Support H2C (HTTP/2 ClearText)
-	// See https://github.com/thrawn01/h2c-golang-example
-	h2s := &http2.Server{}
+	runScenarios(b, "grpc", "grpc+HTTP/2.0", side, conns, func(ctx context.Context, point *pb.Point) error {
+		_, err := client.GetFeature(ctx, point)
+		return err
+	})
+	b.ReportAllocs()
+}
 
-	srv := &http.Server{
-		Addr:    HTTPAddress,
-		Handler: h2c.NewHandler(handler, h2s),
-	}
+func BenchmarkHTTP2(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
 
-	go func() {
-		if err := srv.Serve(listener); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				panic(err)
-			}
-		}
-	}()
+	const HTTPAddress = "localhost:9080"
+	side := startServer(b, HTTPAddress, nil)
+	defer side.Close()
 
 	// Wait for the server in the go routine to start
 	if err := WaitForConnect(ctx, HTTPAddress); err != nil {
@@ -105,14 +153,14 @@ func BenchmarkHTTP2(b *testing.B) {
 
 	// For a fair comparison, we establish a connection the HTTP server before the benchmark test begins by making a
 	// GET call to the server. See WithBlock() on grpc.Dial() in the GRPC benchmark test above
+	conns := newConnStats()
 	hc := &http.Client{
 		Transport: &http2.Transport{
 			// So http2.Transport doesn't complain the URL scheme isn't 'https'
 			AllowHTTP: true,
 			// Pretend we are dialing a TLS endpoint. (Note, we ignore the passed tls.Config)
 			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
-				var d net.Dialer
-				return d.DialContext(ctx, network, addr)
+				return conns.DialContext(ctx, network, addr)
 			},
 		},
 	}
@@ -121,22 +169,15 @@ func BenchmarkHTTP2(b *testing.B) {
 		b.Fatal(err)
 		return
 	}
-	fmt.Printf("Proto: %d\n", r.ProtoMajor)
 	_ = r.Body.Close()
 
 	client := benchmark.NewClient(hc, fmt.Sprintf("http://%s", HTTPAddress))
 
-	b.Run("http.GetFeature()", func(b *testing.B) {
-		for n := 0; n < b.N; n++ {
-			var resp pb.Feature
-			err := client.GetFeature(ctx, &pb.Point{Latitude: 409146138, Longitude: -746188906}, &resp)
-			if err != nil {
-				b.Fatalf("client.GetFeature failed: %v", err)
-			}
-		}
+	runScenarios(b, "http", r.Proto, side, conns, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
 	})
 	b.ReportAllocs()
-	_ = srv.Shutdown(context.Background())
 }
 
 func BenchmarkHTTP1(b *testing.B) {
@@ -144,26 +185,48 @@ func BenchmarkHTTP1(b *testing.B) {
 	defer cancel()
 
 	const HTTPAddress = "localhost:9081"
-	listener, err := net.Listen("tcp", HTTPAddress)
+	side := startServer(b, HTTPAddress, nil)
+	defer side.Close()
+
+	// Wait for the server in the go routine to start
+	if err := WaitForConnect(ctx, HTTPAddress); err != nil {
+		b.Fatal(err)
+	}
+
+	// For a fair comparison, we establish a connection the HTTP server before the benchmark test begins by making a
+	// GET call to the server. See WithBlock() on grpc.Dial() in the GRPC benchmark test above
+	conns := newConnStats()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = conns.DialContext
+	hc := &http.Client{Transport: transport}
+	r, err := hc.Get("http://" + HTTPAddress + "/v1/say.hello")
 	if err != nil {
 		b.Fatal(err)
+		return
 	}
-	defer func() { _ = listener.Close() }()
+	_ = r.Body.Close()
 
-	handler := benchmark.NewHTTPHandler(server.NewRouteGuideServer())
+	client := benchmark.NewClient(hc, fmt.Sprintf("http://%s", HTTPAddress))
 
-	srv := &http.Server{
-		Addr:    HTTPAddress,
-		Handler: handler,
+	runScenarios(b, "http", r.Proto, side, conns, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
+	})
+	b.ReportAllocs()
+}
+
+func BenchmarkHTTPS(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	conf := benchmark.TLSConfig{KeyType: benchmark.KeyType(*keyType), Profile: benchmark.TLSProfile(*tlsProfile)}
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		b.Fatal(err)
 	}
 
-	go func() {
-		if err := srv.Serve(listener); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				panic(err)
-			}
-		}
-	}()
+	const HTTPAddress = "localhost:9082"
+	side := startServer(b, HTTPAddress, &conf)
+	defer side.Close()
 
 	// Wait for the server in the go routine to start
 	if err := WaitForConnect(ctx, HTTPAddress); err != nil {
@@ -172,61 +235,113 @@ func BenchmarkHTTP1(b *testing.B) {
 
 	// For a fair comparison, we establish a connection the HTTP server before the benchmark test begins by making a
 	// GET call to the server. See WithBlock() on grpc.Dial() in the GRPC benchmark test above
-	hc := &http.Client{Transport: http.DefaultTransport}
-	r, err := hc.Get("http://" + HTTPAddress + "/v1/say.hello")
+	conns := newConnStats()
+	hc := &http.Client{
+		Transport: &http2.Transport{
+			TLSClientConfig: conf.ClientTLS,
+			DialTLSContext:  conns.DialTLSContext,
+		},
+	}
+	r, err := hc.Get("https://" + HTTPAddress + "/v1/say.hello")
 	if err != nil {
 		b.Fatal(err)
 		return
 	}
-	fmt.Printf("Proto: %d\n", r.ProtoMajor)
 	_ = r.Body.Close()
 
-	client := benchmark.NewClient(hc, fmt.Sprintf("http://%s", HTTPAddress))
+	client := benchmark.NewClient(hc, fmt.Sprintf("https://%s", HTTPAddress))
 
-	b.Run("http.GetFeature()", func(b *testing.B) {
-		for n := 0; n < b.N; n++ {
-			var resp pb.Feature
-			err := client.GetFeature(ctx, &pb.Point{Latitude: 409146138, Longitude: -746188906}, &resp)
-			if err != nil {
-				b.Fatalf("client.GetFeature failed: %v", err)
-			}
-		}
+	runScenarios(b, "http", r.Proto, side, conns, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
 	})
 	b.ReportAllocs()
-	_ = srv.Shutdown(context.Background())
 }
 
-func BenchmarkHTTPS(b *testing.B) {
+// BenchmarkGRPCTLS is BenchmarkGRPC with TLS, so it can be compared with BenchmarkHTTPS
+func BenchmarkGRPCTLS(b *testing.B) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
-	var conf benchmark.TLSConfig
+	conf := benchmark.TLSConfig{KeyType: benchmark.KeyType(*keyType), Profile: benchmark.TLSProfile(*tlsProfile)}
 	if err := benchmark.SetupTLS(&conf); err != nil {
 		b.Fatal(err)
 	}
 
-	const HTTPAddress = "localhost:9082"
-	listener, err := net.Listen("tcp", HTTPAddress)
+	const GRPCAddress = "localhost:9086"
+	side := startServer(b, GRPCAddress, &conf)
+	defer side.Close()
+
+	// Wait for the server in the go routine to start
+	if err := WaitForConnect(ctx, GRPCAddress); err != nil {
+		b.Fatal(err)
+	}
+
+	conns := newConnStats()
+	conn, err := grpc.Dial(GRPCAddress, grpc.WithTransportCredentials(credentials.NewTLS(conf.ClientTLS)),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return conns.DialContext(ctx, "tcp", addr)
+		}))
 	if err != nil {
+		b.Fatalf("fail to dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := pb.NewRouteGuideClient(conn)
+
+	runScenarios(b, "grpc", "grpc+HTTP/2.0", side, conns, func(ctx context.Context, point *pb.Point) error {
+		_, err := client.GetFeature(ctx, point)
+		return err
+	})
+	b.ReportAllocs()
+}
+
+func BenchmarkFastHTTP(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	const HTTPAddress = "localhost:9083"
+	side := startServer(b, HTTPAddress, nil)
+	defer side.Close()
+
+	// Wait for the server in the go routine to start
+	if err := WaitForConnect(ctx, HTTPAddress); err != nil {
 		b.Fatal(err)
 	}
-	defer func() { _ = listener.Close() }()
 
-	handler := benchmark.NewHTTPHandler(server.NewRouteGuideServer())
+	// For a fair comparison, we establish a connection the HTTP server before the benchmark test begins by making a
+	// GET call to the server. See WithBlock() on grpc.Dial() in the GRPC benchmark test above
+	conns := newConnStats()
+	hc := &fasthttp.Client{
+		// fasthttp redials whenever an idle connection is closed, so the dial must not be
+		// bound to the setup timeout above.
+		Dial: func(addr string) (net.Conn, error) {
+			return conns.DialContext(context.Background(), "tcp", addr)
+		},
+	}
+	if _, _, err := hc.Get(nil, "http://"+HTTPAddress+"/v1/say.hello"); err != nil {
+		b.Fatal(err)
+	}
 
-	srv := &http.Server{
-		TLSConfig: conf.ServerTLS,
-		Addr:      HTTPAddress,
-		Handler:   handler,
+	client, err := benchmark.NewFastHTTPClient(hc, fmt.Sprintf("http://%s", HTTPAddress))
+	if err != nil {
+		b.Fatal(err)
 	}
 
-	go func() {
-		if err := srv.ServeTLS(listener, "", ""); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				panic(err)
-			}
-		}
-	}()
+	runScenarios(b, "http", "HTTP/1.1", side, conns, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
+	})
+	b.ReportAllocs()
+}
+
+func BenchmarkHertz(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	const HTTPAddress = "localhost:9084"
+	side := startServer(b, HTTPAddress, nil)
+	defer side.Close()
 
 	// Wait for the server in the go routine to start
 	if err := WaitForConnect(ctx, HTTPAddress); err != nil {
@@ -235,32 +350,360 @@ func BenchmarkHTTPS(b *testing.B) {
 
 	// For a fair comparison, we establish a connection the HTTP server before the benchmark test begins by making a
 	// GET call to the server. See WithBlock() on grpc.Dial() in the GRPC benchmark test above
-	hc := &http.Client{
-		Transport: &http2.Transport{
-			TLSClientConfig: conf.ClientTLS,
-		},
+	hc, err := hzclient.NewClient()
+	if err != nil {
+		b.Fatal(err)
 	}
-	r, err := hc.Get("https://" + HTTPAddress + "/v1/say.hello")
+	if _, _, err := hc.Get(ctx, nil, "http://"+HTTPAddress+"/v1/say.hello"); err != nil {
+		b.Fatal(err)
+	}
+
+	client, err := benchmark.NewHertzClient(hc, fmt.Sprintf("http://%s", HTTPAddress))
 	if err != nil {
 		b.Fatal(err)
-		return
 	}
-	fmt.Printf("Proto: %d\n", r.ProtoMajor)
-	_ = r.Body.Close()
 
-	client := benchmark.NewClient(hc, fmt.Sprintf("https://%s", HTTPAddress))
+	// Hertz dials and serves connections with netpoll, which cannot be counted by ConnStats, so
+	// -wire reports nothing for Hertz
+	runScenarios(b, "http", "HTTP/1.1", side, nil, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
+	})
+	b.ReportAllocs()
+}
+
+// newConnStats returns the ConnStats which count the connections of a benchmark, or nil, which
+// counts nothing, unless -wire was provided
+func newConnStats() *benchmark.ConnStats {
+	if !*wire {
+		return nil
+	}
+	return &benchmark.ConnStats{}
+}
+
+// serveFunc starts serving the service on the listener for one of the benchmarked transports
+// and returns a func which stops the server
+type serveFunc func(l net.Listener, svc *server.RouteGuideService, conf *benchmark.TLSConfig) (stop func())
+
+// servers are the serveFunc for each benchmark, keyed by the name of the benchmark
+var servers = map[string]serveFunc{
+	"GRPC":      serveGRPC,
+	"GRPCTuned": serveGRPCTuned,
+	"GRPCTLS":   serveGRPCTLS,
+	"HTTP2":     serveH2C,
+	"HTTP1":     serveHTTP1,
+	"HTTPS":     serveHTTPS,
+	"FastHTTP":  serveFastHTTP,
+	"Hertz":     serveHertz,
+}
+
+func serveGRPC(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	grpcServer := grpc.NewServer()
+	pb.RegisterRouteGuideServer(grpcServer, svc)
+	go func() {
+		if err := grpcServer.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}
+	}()
+	return grpcServer.GracefulStop
+}
+
+func serveGRPCTuned(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	grpcServer := grpc.NewServer(benchmark.DefaultGRPCTuning.ServerOptions()...)
+	pb.RegisterRouteGuideServer(grpcServer, svc)
+	go func() {
+		if err := grpcServer.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}
+	}()
+	return grpcServer.GracefulStop
+}
+
+func serveGRPCTLS(l net.Listener, svc *server.RouteGuideService, conf *benchmark.TLSConfig) func() {
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(conf.ServerTLS)))
+	pb.RegisterRouteGuideServer(grpcServer, svc)
+	go func() {
+		if err := grpcServer.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}
+	}()
+	return grpcServer.GracefulStop
+}
+
+func serveH2C(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	// Support H2C (HTTP/2 ClearText)
+	// See https://github.com/thrawn01/h2c-golang-example
+	h2s := &http2.Server{}
+
+	srv := &http.Server{
+		Addr:    l.Addr().String(),
+		Handler: h2c.NewHandler(benchmark.NewHTTPHandler(svc), h2s),
+	}
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}
+	}()
+	return func() { _ = srv.Shutdown(context.Background()) }
+}
+
+func serveHTTP1(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	srv := &http.Server{
+		Addr:    l.Addr().String(),
+		Handler: benchmark.NewHTTPHandler(svc),
+	}
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}
+	}()
+	return func() { _ = srv.Shutdown(context.Background()) }
+}
 
-	b.Run("http.GetFeature()", func(b *testing.B) {
-		for n := 0; n < b.N; n++ {
-			var resp pb.Feature
-			err := client.GetFeature(ctx, &pb.Point{Latitude: 409146138, Longitude: -746188906}, &resp)
+func serveHTTPS(l net.Listener, svc *server.RouteGuideService, conf *benchmark.TLSConfig) func() {
+	srv := &http.Server{
+		TLSConfig: conf.ServerTLS,
+		Addr:      l.Addr().String(),
+		Handler:   benchmark.NewHTTPHandler(svc),
+	}
+	go func() {
+		if err := srv.ServeTLS(l, "", ""); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}
+	}()
+	return func() { _ = srv.Shutdown(context.Background()) }
+}
+
+func serveFastHTTP(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	srv := &fasthttp.Server{Handler: benchmark.NewFastHTTPHandler(svc)}
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			panic(err)
+		}
+	}()
+	return func() { _ = srv.Shutdown() }
+}
+
+func serveHertz(l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig) func() {
+	h := benchmark.NewHertzServer(l, svc)
+	go func() {
+		if err := h.Run(); err != nil {
+			panic(err)
+		}
+	}()
+	return func() { _ = h.Shutdown(context.Background()) }
+}
+
+// scenario is a GetFeature workload which is run against every transport
+type scenario struct {
+	name  string
+	mode  server.ErrorMode
+	point *pb.Point
+	// latency is the artificial delay added to each call by the service
+	latency time.Duration
+	// timeout is the deadline set on the context for each call, if zero no deadline is set
+	timeout time.Duration
+	// wantErr returns true if the error returned is the expected error, if nil no error is expected
+	wantErr func(context.Context, error) bool
+}
+
+var (
+	knownPoint   = &pb.Point{Latitude: 409146138, Longitude: -746188906}
+	unknownPoint = &pb.Point{Latitude: 1, Longitude: 1}
+)
+
+var scenarios = []scenario{
+	{name: "GetFeature()", mode: server.ErrorModeNone, point: knownPoint},
+	{name: "GetFeature(NotFound)", mode: server.ErrorModeNotFound, point: unknownPoint, wantErr: isNotFound},
+	{name: "GetFeature(NotFoundWithDetails)", mode: server.ErrorModeNotFoundWithDetails, point: unknownPoint,
+		wantErr: isNotFound},
+	{name: "GetFeature(DeadlineExceeded)", mode: server.ErrorModeNone, point: knownPoint,
+		latency: 50 * time.Millisecond, timeout: time.Millisecond, wantErr: isDeadlineExceeded},
+}
+
+// runScenarios runs every scenario as a sub benchmark using the provided getFeature func. The
+// protocol is the protocol negotiated by the client and is included in the recorded results.
+// Conns must count the traffic of every connection getFeature uses, if nil the traffic on the wire
+// is not reported.
+func runScenarios(b *testing.B, prefix, protocol string, side serverSide, conns *benchmark.ConnStats,
+	getFeature func(context.Context, *pb.Point) error) {
+	// Scenarios can run longer than the setup context allows when -benchtime is large
+	ctx := context.Background()
+	transport := strings.TrimPrefix(b.Name(), "Benchmark")
+
+	for _, s := range scenarios {
+		// The testing package creates a new testing.B for each repetition requested by `-count`
+		samples := make(map[*testing.B]int)
+		b.Run(prefix+"."+s.name, func(b *testing.B) {
+			sample, ok := samples[b]
+			if !ok {
+				sample = len(samples)
+				samples[b] = sample
+			}
+			if err := side.SetScenario(ctx, benchmark.Scenario{ErrorMode: s.mode, Latency: s.latency}); err != nil {
+				b.Fatal(err)
+			}
+			serverBefore, err := side.Stats(ctx)
 			if err != nil {
-				b.Fatalf("client.GetFeature failed: %v", err)
+				b.Fatal(err)
+			}
+			var prof *benchmark.Profile
+			if *profileDir != "" {
+				prof, err = benchmark.StartProfile(benchmark.ProfileDir(*profileDir, transport, s.name))
+				if err != nil {
+					b.Fatal(err)
+				}
 			}
+
+			var beforeConn benchmark.ConnCounts
+			if conns != nil {
+				beforeConn = conns.Counts()
+			}
+			before := benchmark.ReadUsage()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				if err := s.call(ctx, getFeature); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			client := benchmark.ReadUsage().Sub(before)
+			var clientConn benchmark.ConnCounts
+			if conns != nil {
+				clientConn = conns.Counts().Sub(beforeConn)
+			}
+
+			// Since each call overwrites the profiles, only the last and longest run is kept
+			if prof != nil {
+				if err := prof.Stop(b.N); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			serverAfter, err := side.Stats(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			// Timing every call would add to ns/op, so the latency is recorded by separate calls made
+			// once every other measurement is taken
+			latencies := make([]time.Duration, min(b.N, *latencyCalls))
+			for i := range latencies {
+				start := time.Now()
+				if err := s.call(ctx, getFeature); err != nil {
+					b.Fatal(err)
+				}
+				latencies[i] = time.Since(start)
+			}
+
+			res := benchmark.Result{
+				Transport:   transport,
+				Scenario:    s.name,
+				Sample:      sample,
+				Protocol:    protocol,
+				Iterations:  b.N,
+				NsPerOp:     float64(b.Elapsed().Nanoseconds()) / float64(b.N),
+				AllocsPerOp: float64(client.Mallocs) / float64(b.N),
+				BytesPerOp:  float64(client.TotalAlloc) / float64(b.N),
+				Latency:     benchmark.NewPercentiles(latencies),
+				Metrics:     make(map[string]float64),
+			}
+			report := func(unit string, v float64) {
+				b.ReportMetric(v, unit)
+				res.Metrics[unit] = v
+			}
+
+			// Bytes on the wire, and how well each side batches its writes
+			if conns != nil {
+				serverConn := serverAfter.Conn.Sub(serverBefore.Conn)
+				report("sent-B/op", float64(clientConn.BytesWritten)/float64(b.N))
+				report("recv-B/op", float64(clientConn.BytesRead)/float64(b.N))
+				report("client-writes/op", float64(clientConn.Writes)/float64(b.N))
+				report("client-reads/op", float64(clientConn.Reads)/float64(b.N))
+				report("server-writes/op", float64(serverConn.Writes)/float64(b.N))
+				report("server-reads/op", float64(serverConn.Reads)/float64(b.N))
+			}
+
+			// When the server runs in its own process, allocs/op and B/op only include the client,
+			// so report the server's share separately.
+			if *serverProcess {
+				srv := serverAfter.Usage.Sub(serverBefore.Usage)
+				report("client-cpu-ns/op", float64(client.CPUTime().Nanoseconds())/float64(b.N))
+				report("server-cpu-ns/op", float64(srv.CPUTime().Nanoseconds())/float64(b.N))
+				report("server-allocs/op", float64(srv.Mallocs)/float64(b.N))
+				report("server-B/op", float64(srv.TotalAlloc)/float64(b.N))
+			}
+
+			// Report how often the server stopped working on a call the client abandoned
+			if s.timeout != 0 {
+				report("canceled/op", float64(serverAfter.Canceled-serverBefore.Canceled)/float64(b.N))
+			}
+			recorder.Add(res)
+		})
+	}
+	if err := side.SetScenario(ctx, benchmark.Scenario{}); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// call calls getFeature once and verifies the result is what the scenario expects
+func (s scenario) call(ctx context.Context, getFeature func(context.Context, *pb.Point) error) error {
+	if s.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	err := getFeature(ctx, s.point)
+	if s.wantErr == nil {
+		if err != nil {
+			return fmt.Errorf("client.GetFeature failed: %w", err)
 		}
-	})
-	b.ReportAllocs()
-	_ = srv.Shutdown(context.Background())
+		return nil
+	}
+	if !s.wantErr(ctx, err) {
+		return fmt.Errorf("client.GetFeature returned unexpected error: %v", err)
+	}
+	return nil
+}
+
+// isNotFound returns true if the error is a not found error from either gRPC or DUH
+func isNotFound(_ context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	var de duh.Error
+	if errors.As(err, &de) {
+		return de.Code() == duh.CodeNotFound
+	}
+	return status.Code(err) == codes.NotFound
+}
+
+// isDeadlineExceeded returns true if the call failed because the deadline of the context expired,
+// either on the client or on the server which enforced it
+func isDeadlineExceeded(_ context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	var de duh.Error
+	if errors.As(err, &de) {
+		return de.Code() == benchmark.CodeDeadlineExceeded
+	}
+	return status.Code(err) == codes.DeadlineExceeded
 }
 
 // WaitForConnect waits until the passed address is accepting connections.
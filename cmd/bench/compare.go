@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+// metric is a value extracted from a benchmark.Result which can be compared between runs.
+// For every metric, a larger value is worse.
+type metric struct {
+	name   string
+	value  func(benchmark.Result) float64
+	format func(float64) string
+}
+
+var (
+	nsPerOp     = metric{name: "ns/op", value: func(r benchmark.Result) float64 { return r.NsPerOp }, format: formatNs}
+	allocsPerOp = metric{name: "allocs/op", value: func(r benchmark.Result) float64 { return r.AllocsPerOp }, format: formatCount}
+	bytesPerOp  = metric{name: "B/op", value: func(r benchmark.Result) float64 { return r.BytesPerOp }, format: formatCount}
+	p99         = metric{name: "p99", value: func(r benchmark.Result) float64 { return float64(r.Latency.P99) }, format: formatNs}
+
+	metrics = []metric{nsPerOp, allocsPerOp, bytesPerOp, p99}
+)
+
+// key identifies a transport and scenario within a results file
+type key struct {
+	transport string
+	scenario  string
+}
+
+// comparison is the result of comparing a single metric for a transport and scenario
+type comparison struct {
+	key
+	metric   string
+	old, new summary
+	delta    float64 // percent change of the mean from old to new
+	p        float64 // p-value of the Mann-Whitney U test, NaN if there are not enough samples
+}
+
+// regressed returns true if the new mean is worse than the old mean by more than the threshold and
+// the difference is statistically significant. If there are not enough samples to determine
+// significance, only the threshold is considered.
+func (c comparison) regressed(threshold, alpha float64) bool {
+	if c.delta <= threshold {
+		return false
+	}
+	return !c.testable(alpha) || c.p < alpha
+}
+
+// testable returns true if there are enough samples for the Mann-Whitney U test to reach alpha.
+// With 3 samples of each, for example, the smallest possible p-value is 0.081.
+func (c comparison) testable(alpha float64) bool {
+	return !math.IsNaN(c.p) && minPValue(c.old.n, c.new.n) < alpha
+}
+
+func runCompare(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	threshold := fs.Float64("threshold", 5, "percent increase of a gated metric which is considered a regression")
+	alpha := fs.Float64("alpha", 0.05, "significance level required before a difference is considered a regression")
+	gate := fs.String("gate", "ns/op", "comma separated list of metrics which fail the comparison when they regress")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stdout, "Usage: bench compare [flags] <old-results> <new-results>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return usageError(errors.New("expected exactly two result files"))
+	}
+
+	gated := make(map[string]bool)
+	for _, name := range strings.Split(*gate, ",") {
+		name = strings.TrimSpace(name)
+		if !hasMetric(name) {
+			return usageError(fmt.Errorf("unknown metric '%s' in -gate", name))
+		}
+		gated[name] = true
+	}
+
+	oldResults, err := benchmark.ReadResults(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("while reading '%s': %w", fs.Arg(0), err)
+	}
+	newResults, err := benchmark.ReadResults(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("while reading '%s': %w", fs.Arg(1), err)
+	}
+
+	printEnvironment(stdout, "old", fs.Arg(0), oldResults)
+	printEnvironment(stdout, "new", fs.Arg(1), newResults)
+
+	comparisons := compare(oldResults, newResults)
+	printComparisons(stdout, comparisons, *alpha)
+
+	var untested []string
+	for _, c := range comparisons {
+		if gated[c.metric] && !c.testable(*alpha) {
+			untested = append(untested, fmt.Sprintf("%s %s %s (n=%d+%d)",
+				c.transport, c.scenario, c.metric, c.old.n, c.new.n))
+		}
+	}
+	if len(untested) != 0 {
+		_, _ = fmt.Fprintf(stdout, "\nWARNING: too few samples to reach p < %g, only -threshold is checked for:\n",
+			*alpha)
+		for _, u := range untested {
+			_, _ = fmt.Fprintf(stdout, "  %s\n", u)
+		}
+	}
+
+	regressed := make(map[string]bool)
+	var regressions []string
+	for _, c := range comparisons {
+		if gated[c.metric] && c.regressed(*threshold, *alpha) {
+			regressed[c.transport] = true
+			regressions = append(regressions, fmt.Sprintf("%s %s %s %+.2f%%",
+				c.transport, c.scenario, c.metric, c.delta))
+		}
+	}
+	if len(regressions) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(stdout, "\nRegressions beyond %.2f%%:\n", *threshold)
+	for _, r := range regressions {
+		_, _ = fmt.Fprintf(stdout, "  %s\n", r)
+	}
+	var transports []string
+	for t := range regressed {
+		transports = append(transports, t)
+	}
+	sort.Strings(transports)
+	return exitError{code: 1, msg: "regressed transports: " + strings.Join(transports, ", ")}
+}
+
+// compare compares every metric for each transport and scenario found in both sets of results
+func compare(oldResults, newResults []benchmark.Result) []comparison {
+	oldGroups, keys := group(oldResults)
+	newGroups, _ := group(newResults)
+
+	var comparisons []comparison
+	for _, k := range keys {
+		o, n := oldGroups[k], newGroups[k]
+		if len(n) == 0 {
+			continue
+		}
+		for _, m := range metrics {
+			ov, nv := values(o, m), values(n, m)
+			c := comparison{
+				key:    k,
+				metric: m.name,
+				old:    summarize(ov),
+				new:    summarize(nv),
+				p:      mannWhitneyU(ov, nv),
+			}
+			if c.old.mean != 0 {
+				c.delta = (c.new.mean - c.old.mean) / c.old.mean * 100
+			}
+			comparisons = append(comparisons, c)
+		}
+	}
+	return comparisons
+}
+
+// group groups results by transport and scenario, returning the keys in the order first seen
+func group(results []benchmark.Result) (map[key][]benchmark.Result, []key) {
+	groups := make(map[key][]benchmark.Result)
+	var keys []key
+	for _, r := range results {
+		k := key{transport: r.Transport, scenario: r.Scenario}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], r)
+	}
+	return groups, keys
+}
+
+func values(results []benchmark.Result, m metric) []float64 {
+	v := make([]float64, len(results))
+	for i, r := range results {
+		v[i] = m.value(r)
+	}
+	return v
+}
+
+func hasMetric(name string) bool {
+	for _, m := range metrics {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func printEnvironment(w io.Writer, label, path string, results []benchmark.Result) {
+	if len(results) == 0 {
+		_, _ = fmt.Fprintf(w, "%s: %s (no results)\n", label, path)
+		return
+	}
+	env := results[0].Environment
+	_, _ = fmt.Fprintf(w, "%s: %s (%s %s/%s, %s, grpc %s, duh-go %s, x/net %s, protobuf %s, git %s)\n", label,
+		path, env.GoVersion, env.GOOS, env.GOARCH, env.CPU, env.GRPCVersion, env.DuhVersion, env.NetVersion,
+		env.ProtobufVersion, env.GitSHA)
+}
+
+func printComparisons(w io.Writer, comparisons []comparison, alpha float64) {
+	for _, m := range metrics {
+		_, _ = fmt.Fprintf(w, "\n%s\n", m.name)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "TRANSPORT\tSCENARIO\tOLD\tNEW\tDELTA")
+		for _, c := range comparisons {
+			if c.metric != m.name {
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.transport, c.scenario,
+				c.old.format(m.format), c.new.format(m.format), c.formatDelta(alpha))
+		}
+		_ = tw.Flush()
+	}
+}
+
+// formatDelta formats the delta similar to benchstat, a delta which is not statistically
+// significant is shown as '~'
+func (c comparison) formatDelta(alpha float64) string {
+	n := fmt.Sprintf("n=%d+%d", c.old.n, c.new.n)
+	if !c.testable(alpha) {
+		return fmt.Sprintf("%+.2f%% (%s)", c.delta, n)
+	}
+	if c.p >= alpha {
+		return fmt.Sprintf("~ (p=%.3f %s)", c.p, n)
+	}
+	return fmt.Sprintf("%+.2f%% (p=%.3f %s)", c.delta, c.p, n)
+}
+
+// summary describes a set of samples for a single metric
+type summary struct {
+	n    int
+	mean float64
+	// ci is the half width of the 95% confidence interval of the mean
+	ci float64
+}
+
+func (s summary) format(f func(float64) string) string {
+	if s.n < 2 || s.mean == 0 {
+		return f(s.mean)
+	}
+	return fmt.Sprintf("%s ± %.0f%%", f(s.mean), s.ci/s.mean*100)
+}
+
+func summarize(v []float64) summary {
+	s := summary{n: len(v)}
+	if s.n == 0 {
+		return s
+	}
+	for _, x := range v {
+		s.mean += x
+	}
+	s.mean /= float64(s.n)
+	if s.n < 2 {
+		return s
+	}
+
+	var ss float64
+	for _, x := range v {
+		ss += (x - s.mean) * (x - s.mean)
+	}
+	stddev := math.Sqrt(ss / float64(s.n-1))
+	s.ci = tCritical95(s.n-1) * stddev / math.Sqrt(float64(s.n))
+	return s
+}
+
+// tCritical95 returns the two-tailed 95% critical value of Student's t-distribution
+func tCritical95(df int) float64 {
+	table := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	if df >= 1 && df <= len(table) {
+		return table[df-1]
+	}
+	return 1.960
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test using the normal
+// approximation with tie correction. Returns NaN if either sample has fewer than two values.
+func mannWhitneyU(a, b []float64) float64 {
+	n1, n2 := len(a), len(b)
+	if n1 < 2 || n2 < 2 {
+		return math.NaN()
+	}
+
+	type ranked struct {
+		v     float64
+		first bool
+	}
+	all := make([]ranked, 0, n1+n2)
+	for _, v := range a {
+		all = append(all, ranked{v: v, first: true})
+	}
+	for _, v := range b {
+		all = append(all, ranked{v: v})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign average ranks to ties, and accumulate the tie correction
+	var r1, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	return normalPValue(r1-float64(n1*(n1+1))/2, n1, n2, ties)
+}
+
+// minPValue returns the smallest p-value mannWhitneyU can return for samples of the given sizes,
+// which is when every value of one sample is smaller than every value of the other.
+func minPValue(n1, n2 int) float64 {
+	if n1 < 2 || n2 < 2 {
+		return math.NaN()
+	}
+	return normalPValue(0, n1, n2, 0)
+}
+
+// normalPValue returns the two-sided p-value of the statistic u using the normal approximation
+// with continuity and tie correction
+func normalPValue(u float64, n1, n2 int, ties float64) float64 {
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	mu := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+func formatNs(v float64) string {
+	return time.Duration(v).Round(10 * time.Nanosecond).String()
+}
+
+func formatCount(v float64) string {
+	return fmt.Sprintf("%.1f", v)
+}
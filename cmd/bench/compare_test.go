@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+func TestCompare(t *testing.T) {
+	dir := t.TempDir()
+	base := writeResults(t, filepath.Join(dir, "base.json"), 50000, 51000, 49500, 50500, 50200)
+	same := writeResults(t, filepath.Join(dir, "same.json"), 50100, 50900, 49600, 50400, 50300)
+	slow := writeResults(t, filepath.Join(dir, "slow.json"), 60000, 61000, 59500, 60500, 60200)
+	single := writeResults(t, filepath.Join(dir, "single.json"), 60000)
+	// Slower on average, but the samples overlap too much to be significant
+	noisy := writeResults(t, filepath.Join(dir, "noisy.json"), 45000, 70000, 50000, 52000, 49000)
+	// 3 samples of each can not reach p < 0.05, so only the threshold is checked
+	base3 := writeResults(t, filepath.Join(dir, "base3.json"), 50000, 51000, 49500)
+	slow3 := writeResults(t, filepath.Join(dir, "slow3.json"), 60000, 61000, 59500)
+
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		code     int
+		contains string
+	}{
+		{name: "NoChange", args: []string{base, same}, code: 0, contains: "~ (p="},
+		{name: "Regression", args: []string{base, slow}, code: 1, contains: "GRPC GetFeature() ns/op +19.90%"},
+		{name: "BelowThreshold", args: []string{"-threshold", "25", base, slow}, code: 0, contains: "+19.90%"},
+		{name: "NotSignificant", args: []string{base, noisy}, code: 0, contains: "~ (p="},
+		{name: "SingleSample", args: []string{base, single}, code: 1, contains: "(n=5+1)"},
+		{name: "ThreeSamples", args: []string{base3, slow3}, code: 1, contains: "WARNING: too few samples"},
+		{name: "ThreeSamplesNoChange", args: []string{base3, base3}, code: 0, contains: "+0.00% (n=3+3)"},
+		{name: "AlphaTooSmall", args: []string{"-alpha", "0.001", base, slow}, code: 1,
+			contains: "GRPC GetFeature() ns/op (n=5+5)"},
+		{name: "UnknownGate", args: []string{"-gate", "nope", base, slow}, code: 2},
+		{name: "MissingFile", args: []string{base}, code: 2},
+		{name: "UnknownFlag", args: []string{"-nope", base, slow}, code: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(append([]string{"compare"}, tt.args...), &stdout, &stderr)
+			if code != tt.code {
+				t.Fatalf("expected exit code '%d'; got '%d'\n%s%s", tt.code, code, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.contains) {
+				t.Fatalf("expected output to contain '%s'; got\n%s", tt.contains, stdout.String())
+			}
+		})
+	}
+}
+
+func writeResults(t *testing.T, path string, nsPerOp ...float64) string {
+	t.Helper()
+	rec := benchmark.NewRecorder()
+	for i, ns := range nsPerOp {
+		rec.Add(benchmark.Result{Transport: "GRPC", Scenario: "GetFeature()", Sample: i, NsPerOp: ns})
+	}
+	if err := rec.WriteFile(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,90 @@
+// Command bench provides tools for working with the structured results written by
+// `go test -bench=. -results=<file>`.
+//
+//	bench compare [flags] <old-results> <new-results>
+//	bench report [flags] <results>...
+//	bench profdiff [flags] <transport-a> <transport-b>
+//
+// Commands exit with 1 when they fail, for example when compare finds a regression, and with 2
+// when the command, flags or arguments are invalid.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{name: "compare", usage: "compare two result files and fail if a transport regressed", run: runCompare},
+	{name: "report", usage: "generate markdown tables from result files", run: runReport},
+	{name: "profdiff", usage: "print the functions which differ most between two transport profiles", run: runProfDiff},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		if err := cmd.run(args[1:], stdout); err != nil {
+			_, _ = fmt.Fprintf(stderr, "bench %s: %s\n", cmd.name, err)
+			if e, ok := err.(exitError); ok {
+				return e.code
+			}
+			return 1
+		}
+		return 0
+	}
+	_, _ = fmt.Fprintf(stderr, "bench: unknown command '%s'\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: bench <command> [flags] [args]")
+	_, _ = fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// exitError is returned by commands which need a specific exit code
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e exitError) Error() string {
+	return e.msg
+}
+
+// usageError exits with 2 like an unknown command does, so scripts can tell a mistake in the
+// flags or arguments apart from the failure the command reports with 1
+func usageError(err error) error {
+	return exitError{code: 2, msg: err.Error()}
+}
+
+// parseError returns the error of flag.FlagSet.Parse as a usage error; -h succeeds
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return usageError(err)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/google/pprof/profile"
+)
+
+func runProfDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("profdiff", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	dir := fs.String("dir", "profiles", "directory passed to `go test -profile-dir`")
+	scenario := fs.String("scenario", "GetFeature()", "scenario to compare")
+	kind := fs.String("kind", benchmark.ProfileCPU, "profile to compare; one of cpu, heap, mutex or block")
+	sampleIndex := fs.String("sample_index", "", "sample type to compare, defaults to the profile's default sample type")
+	top := fs.Int("n", 20, "number of functions to print")
+	cum := fs.Bool("cum", false, "compare cumulative values instead of flat values")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stdout, "Usage: bench profdiff [flags] <transport-a> <transport-b>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return usageError(errors.New("expected exactly two transports"))
+	}
+
+	a, err := readProfile(*dir, fs.Arg(0), *scenario, *kind)
+	if err != nil {
+		return err
+	}
+	b, err := readProfile(*dir, fs.Arg(1), *scenario, *kind)
+	if err != nil {
+		return err
+	}
+
+	idx, err := sampleTypeIndex(a, *sampleIndex)
+	if err != nil {
+		return err
+	}
+	st := a.SampleType[idx]
+
+	av, bv := perOp(a, idx, *cum), perOp(b, idx, *cum)
+	diffs := diffFunctions(av, bv)
+	if *top > 0 && len(diffs) > *top {
+		diffs = diffs[:*top]
+	}
+
+	mode := "flat"
+	if *cum {
+		mode = "cum"
+	}
+	_, _ = fmt.Fprintf(stdout, "%s profile, %s per op (%s), %s vs %s, %s\n",
+		*kind, st.Type, mode, fs.Arg(0), fs.Arg(1), *scenario)
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintf(tw, "%s\t%s\tDELTA\t FUNCTION\n", fs.Arg(0), fs.Arg(1))
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t %s\n", formatUnit(d.a, st.Unit), formatUnit(d.b, st.Unit),
+			formatDeltaUnit(d.a-d.b, st.Unit), d.function)
+	}
+	return tw.Flush()
+}
+
+func readProfile(dir, transport, scenario, kind string) (*profile.Profile, error) {
+	path := filepath.Join(benchmark.ProfileDir(dir, transport, scenario), kind+".pprof")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing '%s': %w", path, err)
+	}
+	return p, nil
+}
+
+// sampleTypeIndex returns the index of the named sample type, or the default sample type if name is empty
+func sampleTypeIndex(p *profile.Profile, name string) (int, error) {
+	if name == "" {
+		name = p.DefaultSampleType
+	}
+	if name == "" {
+		return len(p.SampleType) - 1, nil
+	}
+	for i, st := range p.SampleType {
+		if st.Type == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("sample type '%s' not found in profile", name)
+}
+
+// perOp returns the value of each function in the profile divided by the iterations of the run
+func perOp(p *profile.Profile, idx int, cum bool) map[string]float64 {
+	iterations := float64(benchmark.ProfileIterations(p))
+	if iterations == 0 {
+		iterations = 1
+	}
+
+	values := make(map[string]float64)
+	for _, s := range p.Sample {
+		v := float64(s.Value[idx]) / iterations
+		if v == 0 {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, loc := range s.Location {
+			for _, line := range loc.Line {
+				if line.Function == nil || seen[line.Function.Name] {
+					continue
+				}
+				seen[line.Function.Name] = true
+				values[line.Function.Name] += v
+				if !cum {
+					break
+				}
+			}
+			if !cum && len(seen) != 0 {
+				break
+			}
+		}
+	}
+	return values
+}
+
+type functionDiff struct {
+	function string
+	a, b     float64
+}
+
+// diffFunctions returns every function found in either profile sorted by the largest absolute difference
+func diffFunctions(a, b map[string]float64) []functionDiff {
+	var diffs []functionDiff
+	for fn, v := range a {
+		diffs = append(diffs, functionDiff{function: fn, a: v, b: b[fn]})
+	}
+	for fn, v := range b {
+		if _, ok := a[fn]; !ok {
+			diffs = append(diffs, functionDiff{function: fn, b: v})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		di, dj := math.Abs(diffs[i].a-diffs[i].b), math.Abs(diffs[j].a-diffs[j].b)
+		if di != dj {
+			return di > dj
+		}
+		return diffs[i].function < diffs[j].function
+	})
+	return diffs
+}
+
+func formatUnit(v float64, unit string) string {
+	switch unit {
+	case "nanoseconds":
+		return time.Duration(v).Round(time.Nanosecond).String()
+	case "bytes":
+		return fmt.Sprintf("%.0fB", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func formatDeltaUnit(v float64, unit string) string {
+	if v < 0 {
+		return "-" + formatUnit(-v, unit)
+	}
+	return "+" + formatUnit(v, unit)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestProfDiffPerOp(t *testing.T) {
+	fn := func(id uint64, name string) *profile.Location {
+		return &profile.Location{ID: id, Line: []profile.Line{{Function: &profile.Function{ID: id, Name: name}}}}
+	}
+	write, framer, roundTrip := fn(1, "write"), fn(2, "framer"), fn(3, "RoundTrip")
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{write, framer, roundTrip}, Value: []int64{300}},
+			{Location: []*profile.Location{framer, roundTrip}, Value: []int64{100}},
+		},
+		Comments: []string{"iterations=10"},
+	}
+
+	for _, test := range []struct {
+		name     string
+		cum      bool
+		expected map[string]float64
+	}{
+		{
+			name:     "Flat",
+			expected: map[string]float64{"write": 30, "framer": 10},
+		},
+		{
+			name:     "Cumulative",
+			cum:      true,
+			expected: map[string]float64{"write": 30, "framer": 40, "RoundTrip": 40},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := perOp(p, 0, test.cum)
+			if len(got) != len(test.expected) {
+				t.Fatalf("expected '%v'; got '%v'", test.expected, got)
+			}
+			for fn, v := range test.expected {
+				if got[fn] != v {
+					t.Errorf("expected '%s' to be '%v'; got '%v'", fn, v, got[fn])
+				}
+			}
+		})
+	}
+
+	diffs := diffFunctions(map[string]float64{"write": 30, "framer": 10}, map[string]float64{"write": 25, "writeHeaders": 20})
+	var order []string
+	for _, d := range diffs {
+		order = append(order, d.function)
+	}
+	if len(order) != 3 || order[0] != "writeHeaders" || order[1] != "framer" || order[2] != "write" {
+		t.Errorf("expected functions sorted by absolute delta; got '%v'", order)
+	}
+}
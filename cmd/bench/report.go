@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+const (
+	// readmeBegin and readmeEnd mark the section of the README which is replaced by `bench report -readme`
+	readmeBegin = "<!-- bench-report:begin -->"
+	readmeEnd   = "<!-- bench-report:end -->"
+	// baseline is the transport all other transports are compared against
+	baseline = "GRPC"
+)
+
+func runReport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	readme := fs.String("readme", "", "replace the generated section of this markdown file instead of printing the report")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stdout, "Usage: bench report [flags] <results>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageError(errors.New("expected at least one result file"))
+	}
+
+	var results []benchmark.Result
+	for _, path := range fs.Args() {
+		r, err := benchmark.ReadResults(path)
+		if err != nil {
+			return fmt.Errorf("while reading '%s': %w", path, err)
+		}
+		results = append(results, r...)
+	}
+
+	var buf bytes.Buffer
+	writeReport(&buf, results)
+
+	if *readme == "" {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	return replaceSection(*readme, buf.Bytes())
+}
+
+// writeReport writes a markdown section for each platform found in the results, with a table
+// for each scenario comparing every transport to gRPC.
+func writeReport(w io.Writer, results []benchmark.Result) {
+	platforms := make(map[string][]benchmark.Result)
+	var order []string
+	for _, r := range results {
+		p := platform(r.Environment)
+		if _, ok := platforms[p]; !ok {
+			order = append(order, p)
+		}
+		platforms[p] = append(platforms[p], r)
+	}
+	sort.Strings(order)
+
+	for i, p := range order {
+		if i != 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		writePlatform(w, p, platforms[p])
+	}
+}
+
+func writePlatform(w io.Writer, name string, results []benchmark.Result) {
+	env := results[0].Environment
+	_, _ = fmt.Fprintf(w, "#### %s\n", name)
+	_, _ = fmt.Fprintf(w, "%s, GOMAXPROCS=%d, grpc %s, duh-go %s, x/net %s, protobuf %s, git %s, %s\n",
+		env.GoVersion, env.GOMAXPROCS, env.GRPCVersion, env.DuhVersion, env.NetVersion, env.ProtobufVersion,
+		shortSHA(env.GitSHA),
+		env.Timestamp.Format("2006-01-02"))
+
+	groups, keys := group(results)
+	var scenarios []string
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if !seen[k.scenario] {
+			seen[k.scenario] = true
+			scenarios = append(scenarios, k.scenario)
+		}
+	}
+
+	for _, scenario := range scenarios {
+		var base summary
+		if g, ok := groups[key{transport: baseline, scenario: scenario}]; ok {
+			base = summarize(values(g, nsPerOp))
+		}
+
+		_, _ = fmt.Fprintf(w, "\n`%s`\n\n", scenario)
+		_, _ = fmt.Fprintln(w, "| Transport | Protocol | ns/op | allocs/op | B/op | p99 | vs gRPC |")
+		_, _ = fmt.Fprintln(w, "|---|---|---:|---:|---:|---:|---:|")
+		for _, k := range keys {
+			if k.scenario != scenario {
+				continue
+			}
+			g := groups[k]
+			ns := summarize(values(g, nsPerOp))
+			ratio := "n/a"
+			if base.mean != 0 {
+				ratio = fmt.Sprintf("%.2fx", ns.mean/base.mean)
+			}
+			_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n",
+				k.transport, g[0].Protocol,
+				ns.format(formatNs),
+				formatCount(summarize(values(g, allocsPerOp)).mean),
+				formatCount(summarize(values(g, bytesPerOp)).mean),
+				formatNs(summarize(values(g, p99)).mean),
+				ratio)
+		}
+	}
+}
+
+// platform describes the machine the results were recorded on
+func platform(env benchmark.Environment) string {
+	return fmt.Sprintf("%s/%s (%s)", env.GOOS, env.GOARCH, env.CPU)
+}
+
+func shortSHA(sha string) string {
+	short, dirty := strings.CutSuffix(sha, "-dirty")
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	if dirty {
+		short += "-dirty"
+	}
+	return short
+}
+
+// replaceSection replaces the content between readmeBegin and readmeEnd in the file
+func replaceSection(path string, content []byte) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	begin := bytes.Index(b, []byte(readmeBegin))
+	end := bytes.Index(b, []byte(readmeEnd))
+	if begin == -1 || end == -1 || end < begin {
+		return fmt.Errorf("'%s' must contain '%s' followed by '%s'", path, readmeBegin, readmeEnd)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(b[:begin+len(readmeBegin)])
+	buf.WriteString("\n")
+	buf.Write(content)
+	buf.Write(b[end:])
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+func TestReportReadme(t *testing.T) {
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results.json")
+	rec := benchmark.NewRecorder()
+	rec.Add(benchmark.Result{Transport: "GRPC", Scenario: "GetFeature()", Protocol: "grpc+HTTP/2.0", NsPerOp: 50000})
+	rec.Add(benchmark.Result{Transport: "HTTP1", Scenario: "GetFeature()", Protocol: "HTTP/1.1", NsPerOp: 25000})
+	if err := rec.WriteFile(results, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	readme := filepath.Join(dir, "README.md")
+	before := "### Results\n" + readmeBegin + "\nhand pasted results\n" + readmeEnd + "\n### After\n"
+	if err := os.WriteFile(readme, []byte(before), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"report", "-readme", readme, results}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code '0'; got '%d'\n%s", code, stderr.String())
+	}
+
+	b, err := os.ReadFile(readme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, expected := range []string{
+		"### Results\n" + readmeBegin + "\n#### ",
+		"| GRPC | grpc+HTTP/2.0 | 50µs | 0.0 | 0.0 | 0s | 1.00x |",
+		"| HTTP1 | HTTP/1.1 | 25µs | 0.0 | 0.0 | 0s | 0.50x |",
+		readmeEnd + "\n### After\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected README to contain '%s'; got\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "hand pasted results") {
+		t.Errorf("expected generated section to be replaced; got\n%s", got)
+	}
+}
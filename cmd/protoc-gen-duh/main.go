@@ -0,0 +1,273 @@
+// Command protoc-gen-duh is a protoc plugin which generates DUH-RPC clients, server interfaces
+// and routers for the services in a .proto file.
+//
+//	protoc --go_out=. --duh_out=. --duh_opt=streaming=skip route_guide.proto
+//
+// For each service it generates:
+//
+//   - A constant with the path of every method, which is `<prefix>/<service>.<method>` with the
+//     first letter of the service and method in lower case, for example `/v1/routeGuide.getFeature`.
+//   - A `<Service>DUHServer` interface with a method for every unary RPC.
+//   - A `<Service>DUHHandler` which routes requests to the server using a switch on the path.
+//   - A `<Service>DUHClient` with a method for every unary RPC.
+//
+// DUH-RPC is a request/reply protocol, so streaming RPCs can not be generated. The plugin fails
+// on a service with streaming RPCs unless passed `streaming=skip`, in which case it warns about
+// every streaming RPC on stderr and lists them in the doc comment of the server interface.
+//
+// The plugin accepts the parameters `prefix`, which defaults to `/v1`, and `streaming`, which is
+// `error` (the default) or `skip`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const (
+	bytesPackage   = protogen.GoImportPath("bytes")
+	contextPackage = protogen.GoImportPath("context")
+	fmtPackage     = protogen.GoImportPath("fmt")
+	httpPackage    = protogen.GoImportPath("net/http")
+	duhPackage     = protogen.GoImportPath("github.com/duh-rpc/duh-go")
+	protoPackage   = protogen.GoImportPath("google.golang.org/protobuf/proto")
+)
+
+func main() {
+	var flags flag.FlagSet
+	prefix := flags.String("prefix", "/v1", "prefix of the path of every method")
+	streaming := flags.String("streaming", "error", "what to do with streaming RPCs; 'error' or 'skip'")
+
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
+		return generate(gen, *prefix, *streaming, os.Stderr)
+	})
+}
+
+// generate generates the DUH-RPC code of every file. If streaming is 'skip' the streaming RPCs
+// are reported to warnings and skipped, otherwise they fail the generation.
+func generate(gen *protogen.Plugin, prefix, streaming string, warnings io.Writer) error {
+	if streaming != "error" && streaming != "skip" {
+		return fmt.Errorf("unknown value '%s' for parameter 'streaming'; expected 'error' or 'skip'", streaming)
+	}
+	gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	for _, f := range gen.Files {
+		if !f.Generate || len(f.Services) == 0 {
+			continue
+		}
+		if names := streamingMethods(f); len(names) != 0 {
+			if streaming == "error" {
+				return fmt.Errorf("%s: DUH-RPC is a request/reply protocol and can not serve the streaming "+
+					"methods %s; pass 'streaming=skip' to generate the unary methods only",
+					f.Desc.Path(), strings.Join(names, ", "))
+			}
+			for _, name := range names {
+				_, _ = fmt.Fprintf(warnings, "protoc-gen-duh: %s: skipping streaming method %s\n",
+					f.Desc.Path(), name)
+			}
+		}
+		generateFile(gen, f, strings.TrimSuffix(prefix, "/"))
+	}
+	return nil
+}
+
+// streamingMethods returns the names of the streaming methods of every service in the file, in
+// the form `<Service>.<Method>`
+func streamingMethods(file *protogen.File) []string {
+	var names []string
+	for _, service := range file.Services {
+		for _, m := range service.Methods {
+			if isStreaming(m) {
+				names = append(names, service.GoName+"."+m.GoName)
+			}
+		}
+	}
+	return names
+}
+
+func isStreaming(m *protogen.Method) bool {
+	return m.Desc.IsStreamingClient() || m.Desc.IsStreamingServer()
+}
+
+func generateFile(gen *protogen.Plugin, file *protogen.File, prefix string) {
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_duh.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-duh. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, service := range file.Services {
+		generateService(g, service, prefix)
+	}
+}
+
+func generateService(g *protogen.GeneratedFile, service *protogen.Service, prefix string) {
+	var unary, streaming []*protogen.Method
+	for _, m := range service.Methods {
+		if isStreaming(m) {
+			streaming = append(streaming, m)
+			continue
+		}
+		unary = append(unary, m)
+	}
+
+	name := service.GoName
+	serverName := name + "DUHServer"
+	handlerName := name + "DUHHandler"
+	clientName := name + "DUHClient"
+	configName := name + "DUHClientConfig"
+
+	// Paths
+	g.P("const (")
+	for _, m := range unary {
+		g.P(pathName(m), " = ", fmt.Sprintf("%q", path(prefix, service, m)))
+	}
+	g.P(")")
+	g.P()
+
+	// Server interface
+	g.P("// ", serverName, " is the server API for the ", name, " service served over DUH-RPC.")
+	if len(streaming) != 0 {
+		g.P("//")
+		g.P("// DUH-RPC is a request/reply protocol, so the following streaming methods are not included:")
+		for _, m := range streaming {
+			g.P("// ", m.GoName)
+		}
+	}
+	g.P("type ", serverName, " interface {")
+	for _, m := range unary {
+		g.Annotate(serverName+"."+m.GoName, m.Location)
+		g.P(m.Comments.Leading, m.GoName, "(", g.QualifiedGoIdent(contextPackage.Ident("Context")), ", *",
+			g.QualifiedGoIdent(m.Input.GoIdent), ") (*", g.QualifiedGoIdent(m.Output.GoIdent), ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	// Handler
+	g.P("// New", handlerName, " returns a handler which routes DUH-RPC requests to the server")
+	g.P("func New", handlerName, "(server ", serverName, ") *", handlerName, " {")
+	g.P("return &", handlerName, "{server: server}")
+	g.P("}")
+	g.P()
+	g.P("type ", handlerName, " struct {")
+	g.P("server ", serverName)
+	g.P("}")
+	g.P()
+	g.P("func (h *", handlerName, ") ServeHTTP(w ", httpPackage.Ident("ResponseWriter"), ", r *",
+		httpPackage.Ident("Request"), ") {")
+	g.P("if r.Method != ", httpPackage.Ident("MethodPost"), " {")
+	g.P(duhPackage.Ident("ReplyWithCode"), "(w, r, ", duhPackage.Ident("CodeBadRequest"), ", nil,")
+	g.P(fmtPackage.Ident("Sprintf"), `("http method '%s' not allowed; only POST", r.Method))`)
+	g.P("return")
+	g.P("}")
+	g.P()
+	g.P("// No need for fancy routers, a switch case is performant and simple.")
+	g.P("switch r.URL.Path {")
+	for _, m := range unary {
+		g.P("case ", pathName(m), ":")
+		g.P("h.handle", m.GoName, "(w, r)")
+		g.P("return")
+	}
+	g.P("}")
+	g.P(duhPackage.Ident("ReplyWithCode"), "(w, r, ", duhPackage.Ident("CodeNotImplemented"),
+		`, nil, "no such method; "+r.URL.Path)`)
+	g.P("}")
+	g.P()
+	for _, m := range unary {
+		g.P("func (h *", handlerName, ") handle", m.GoName, "(w ", httpPackage.Ident("ResponseWriter"), ", r *",
+			httpPackage.Ident("Request"), ") {")
+		g.P("var req ", m.Input.GoIdent)
+		g.P("if err := ", duhPackage.Ident("ReadRequest"), "(r, &req); err != nil {")
+		g.P(duhPackage.Ident("ReplyError"), "(w, r, err)")
+		g.P("return")
+		g.P("}")
+		g.P("resp, err := h.server.", m.GoName, "(r.Context(), &req)")
+		g.P("if err != nil {")
+		g.P(duhPackage.Ident("ReplyError"), "(w, r, err)")
+		g.P("return")
+		g.P("}")
+		g.P(duhPackage.Ident("Reply"), "(w, r, ", duhPackage.Ident("CodeOK"), ", resp)")
+		g.P("}")
+		g.P()
+	}
+
+	// Client
+	g.P("type ", configName, " struct {")
+	g.P("// The address of the server, for example 'http://localhost:8080'")
+	g.P("Endpoint string")
+	g.P()
+	g.P("// (Optional) The client used to make requests. Defaults to http.DefaultClient")
+	g.P("Client *", httpPackage.Ident("Client"))
+	g.P()
+	g.P("// (Optional) Prepare is called with every request before it is sent. If it returns an error")
+	g.P("// the request is not sent and the error is returned to the caller.")
+	g.P("Prepare func(", contextPackage.Ident("Context"), ", *", httpPackage.Ident("Request"), ") error")
+	g.P("}")
+	g.P()
+	g.P("// ", clientName, " is a client for the ", name, " service served over DUH-RPC")
+	g.P("type ", clientName, " struct {")
+	g.P("*", duhPackage.Ident("Client"))
+	g.P("endpoint string")
+	g.P("prepare func(", contextPackage.Ident("Context"), ", *", httpPackage.Ident("Request"), ") error")
+	g.P("}")
+	g.P()
+	g.P("func New", clientName, "(conf ", configName, ") *", clientName, " {")
+	g.P("if conf.Client == nil {")
+	g.P("conf.Client = ", httpPackage.Ident("DefaultClient"))
+	g.P("}")
+	g.P("return &", clientName, "{")
+	g.P("Client: &", duhPackage.Ident("Client"), "{Client: conf.Client},")
+	g.P("endpoint: conf.Endpoint,")
+	g.P("prepare: conf.Prepare,")
+	g.P("}")
+	g.P("}")
+	g.P()
+	for _, m := range unary {
+		g.P(m.Comments.Leading.String() + "func (c *" + clientName + ") " + m.GoName + "(ctx " +
+			g.QualifiedGoIdent(contextPackage.Ident("Context")) + ", req *" + g.QualifiedGoIdent(m.Input.GoIdent) +
+			", resp *" + g.QualifiedGoIdent(m.Output.GoIdent) + ") error {")
+		g.P("payload, err := ", protoPackage.Ident("Marshal"), "(req)")
+		g.P("if err != nil {")
+		g.P("return ", duhPackage.Ident("NewClientError"), "(", fmtPackage.Ident("Errorf"),
+			`("while marshaling request payload: %w", err), nil)`)
+		g.P("}")
+		g.P()
+		g.P("r, err := ", httpPackage.Ident("NewRequestWithContext"), "(ctx, ", httpPackage.Ident("MethodPost"),
+			", c.endpoint+", pathName(m), ", ", bytesPackage.Ident("NewReader"), "(payload))")
+		g.P("if err != nil {")
+		g.P("return ", duhPackage.Ident("NewClientError"), "(err, nil)")
+		g.P("}")
+		g.P("r.Header.Set(\"Content-Type\", ", duhPackage.Ident("ContentTypeProtoBuf"), ")")
+		g.P()
+		g.P("if c.prepare != nil {")
+		g.P("if err := c.prepare(ctx, r); err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("}")
+		g.P("return c.Do(r, resp)")
+		g.P("}")
+		g.P()
+	}
+}
+
+// pathName returns the name of the constant holding the path of the method
+func pathName(m *protogen.Method) string {
+	return m.Parent.GoName + m.GoName + "Path"
+}
+
+func path(prefix string, service *protogen.Service, m *protogen.Method) string {
+	return fmt.Sprintf("%s/%s.%s", prefix, lowerFirst(string(service.Desc.Name())), lowerFirst(string(m.Desc.Name())))
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// newPlugin returns a plugin which generates route_guide.proto
+func newPlugin(t *testing.T) *protogen.Plugin {
+	t.Helper()
+	fd := protodesc.ToFileDescriptorProto(pb.File_v1_route_guide_proto)
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{fd},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+func TestGenerate(t *testing.T) {
+	gen := newPlugin(t)
+	var warnings bytes.Buffer
+	if err := generate(gen, "/v2/", "skip", &warnings); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"RouteGuide.ListFeatures", "RouteGuide.RecordRoute", "RouteGuide.RouteChat"} {
+		if !strings.Contains(warnings.String(), "skipping streaming method "+name+"\n") {
+			t.Errorf("expected a warning for '%s'; got '%s'", name, warnings.String())
+		}
+	}
+
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Fatalf("expected one generated file; got '%d'", len(resp.File))
+	}
+	if name := resp.File[0].GetName(); !strings.HasSuffix(name, "route_guide_duh.pb.go") {
+		t.Errorf("unexpected file name '%s'", name)
+	}
+
+	content := resp.File[0].GetContent()
+	for _, expected := range []string{
+		`RouteGuideGetFeaturePath = "/v2/routeGuide.getFeature"`,
+		"GetFeature(context.Context, *Point) (*Feature, error)",
+		"func (c *RouteGuideDUHClient) GetFeature(ctx context.Context, req *Point, resp *Feature) error",
+		"// ListFeatures\n// RecordRoute\n// RouteChat\n",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("expected generated code to contain '%s'\n%s", expected, content)
+		}
+	}
+	// Streaming methods are only listed in the doc comment
+	if strings.Contains(content, "handleRecordRoute") {
+		t.Errorf("expected streaming methods to be skipped\n%s", content)
+	}
+}
+
+func TestGenerateStreaming(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		streaming string
+		expected  string
+	}{
+		{
+			name:      "Error",
+			streaming: "error",
+			expected: "can not serve the streaming methods RouteGuide.ListFeatures, RouteGuide.RecordRoute, " +
+				"RouteGuide.RouteChat; pass 'streaming=skip'",
+		},
+		{
+			name:      "Unknown",
+			streaming: "drop",
+			expected:  "unknown value 'drop' for parameter 'streaming'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			gen := newPlugin(t)
+			err := generate(gen, "/v1", test.streaming, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("expected error containing '%s'; got '%v'", test.expected, err)
+			}
+			if len(gen.Response().File) != 0 {
+				t.Errorf("expected no generated files; got '%d'", len(gen.Response().File))
+			}
+		})
+	}
+}
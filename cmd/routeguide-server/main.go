@@ -0,0 +1,466 @@
+// Command routeguide-server serves the RouteGuideService over any mix of gRPC, tuned gRPC, HTTP/1,
+// HTTP/2 (H2C), HTTP/2 (TLS), HTTP/2 with mutual TLS, and HTTP/1 with fasthttp or Hertz, so
+// benchmarks can run the server outside the client's process.
+//
+//	routeguide-server -grpc localhost:9081 -http1 localhost:9083 -https localhost:9082
+//
+// Once every listener is accepting connections the address of each listener is written to
+// stdout as `<flag>: <address>`, followed by the address of the admin endpoint as the last
+// line, and `GET /ready` on the admin endpoint returns 200. The admin endpoint also serves
+// the control endpoints used by the benchmarks to configure and measure the service. The
+// server shuts down on SIGINT or SIGTERM.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// AdminPrefix prefixes the line written to stdout with the address of the admin endpoint
+const AdminPrefix = "admin: "
+
+// tlsFiles are the PEM files read from, or written to, the `-tls-dir` directory
+var tlsFiles = []struct {
+	name string
+	file func(conf *benchmark.TLSConfig) *string
+	pem  func(conf *benchmark.TLSConfig) **bytes.Buffer
+}{
+	{
+		name: "ca.pem",
+		file: func(c *benchmark.TLSConfig) *string { return &c.CaFile },
+		pem:  func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CaPEM },
+	},
+	{
+		name: "ca.key",
+		file: func(c *benchmark.TLSConfig) *string { return &c.CaKeyFile },
+		pem:  func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CaKeyPEM },
+	},
+	{
+		name: "cert.pem",
+		file: func(c *benchmark.TLSConfig) *string { return &c.CertFile },
+		pem:  func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CertPEM },
+	},
+	{
+		name: "cert.key",
+		file: func(c *benchmark.TLSConfig) *string { return &c.KeyFile },
+		pem:  func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.KeyPEM },
+	},
+}
+
+type config struct {
+	grpc, grpcTuned, grpcTLS, http1 string
+	h2c, https, mtls, fasthttp      string
+	hertz                           string
+	admin                           string
+	tlsDir                          string
+	tlsKeyType                      string
+	tlsProfile                      string
+	tlsReload                       time.Duration
+	keepalive                       time.Duration
+	wire                            bool
+
+	// HTTP/2 flow control settings of the h2c, https, mtls and grpc servers
+	streamWindow, connWindow, maxFrameSize int
+	writeScheduler                         string
+}
+
+func main() {
+	var conf config
+	fs := flag.NewFlagSet("routeguide-server", flag.ExitOnError)
+	fs.StringVar(&conf.grpc, "grpc", "", "address to serve gRPC on")
+	fs.StringVar(&conf.grpcTuned, "grpc-tuned", "", "address to serve gRPC tuned by benchmark.DefaultGRPCTuning on")
+	fs.StringVar(&conf.grpcTLS, "grpc-tls", "", "address to serve gRPC with TLS on")
+	fs.StringVar(&conf.http1, "http1", "", "address to serve HTTP/1 on")
+	fs.StringVar(&conf.h2c, "h2c", "", "address to serve HTTP/2 without TLS (H2C) on")
+	fs.StringVar(&conf.https, "https", "", "address to serve HTTP/2 with TLS on")
+	fs.StringVar(&conf.mtls, "mtls", "", "address to serve HTTP/2 with TLS and required client certificates on")
+	fs.StringVar(&conf.fasthttp, "fasthttp", "", "address to serve HTTP/1 with fasthttp on")
+	fs.StringVar(&conf.hertz, "hertz", "", "address to serve HTTP/1 with Hertz on; the traffic of Hertz is never "+
+		"counted by -wire")
+	fs.StringVar(&conf.admin, "admin", "localhost:0", "address to serve the readiness and control endpoints on")
+	fs.StringVar(&conf.tlsDir, "tls-dir", "", "directory containing ca.pem, ca.key, cert.pem and cert.key; "+
+		"cert.pem may be followed by intermediate certificates. If neither cert.pem nor cert.key exist they are "+
+		"generated, signed by ca.pem and ca.key if they exist, and written to the directory")
+	fs.StringVar(&conf.tlsKeyType, "tls-key-type", string(benchmark.KeyTypeP256), "algorithm of the keys "+
+		"generated into -tls-dir; one of P-256, P-384, P-521, Ed25519, RSA-2048 or RSA-4096")
+	fs.StringVar(&conf.tlsProfile, "tls-profile", string(benchmark.TLSProfileDefault), "TLS versions and "+
+		"cipher suites of -https, -mtls and -grpc-tls; one of Default, TLS1.3, TLS1.2-AES128-GCM, "+
+		"TLS1.2-AES256-GCM or TLS1.2-ChaCha20")
+	fs.DurationVar(&conf.tlsReload, "tls-reload", 0, "check cert.pem and cert.key in -tls-dir for a new key "+
+		"pair at most once per interval during handshakes; zero never reloads them")
+	fs.BoolVar(&conf.wire, "wire", false, "count the bytes and Read()/Write() calls on every connection and "+
+		"report them through the control endpoint")
+	fs.DurationVar(&conf.keepalive, "keepalive", 0, "interval of gRPC keepalive pings sent by the server, and "+
+		"the shortest interval the server permits clients to ping; zero uses the gRPC defaults")
+	fs.IntVar(&conf.streamWindow, "h2-stream-window", 0, "initial HTTP/2 flow control window of each stream "+
+		"for -h2c, -https, -mtls, -grpc and -grpc-tls, which only limits request bodies for -h2c, -https and "+
+		"-mtls; zero uses the default")
+	fs.IntVar(&conf.connWindow, "h2-conn-window", 0, "initial HTTP/2 flow control window of each connection "+
+		"for -h2c, -https, -mtls, -grpc and -grpc-tls, which only limits request bodies for -h2c, -https and "+
+		"-mtls; zero uses the default")
+	fs.IntVar(&conf.maxFrameSize, "h2-max-frame", 0, "largest HTTP/2 frame read by -h2c, -https and -mtls; "+
+		"zero uses the default")
+	fs.StringVar(&conf.writeScheduler, "h2-write-scheduler", "", "HTTP/2 write scheduler of -h2c, -https and "+
+		"-mtls; 'priority' or 'random'")
+	_ = fs.Parse(os.Args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, conf, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, conf config, stdout io.Writer) error {
+	if conf.grpc == "" && conf.grpcTuned == "" && conf.grpcTLS == "" && conf.http1 == "" && conf.h2c == "" &&
+		conf.https == "" && conf.mtls == "" && conf.fasthttp == "" && conf.hertz == "" {
+		return errors.New("at least one of -grpc, -grpc-tuned, -grpc-tls, -http1, -h2c, -https, -mtls, " +
+			"-fasthttp or -hertz is required")
+	}
+
+	h2 := benchmark.HTTP2Tuning{
+		StreamWindow:   int32(conf.streamWindow),
+		ConnWindow:     int32(conf.connWindow),
+		MaxFrameSize:   uint32(conf.maxFrameSize),
+		WriteScheduler: conf.writeScheduler,
+	}
+	if err := h2.Validate(); err != nil {
+		return err
+	}
+
+	svc := server.NewRouteGuideServer()
+	var ready atomic.Bool
+	var stops []func()
+	defer func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}()
+
+	// The address of each listener is written to stdout, so callers can use port zero. With -wire,
+	// the traffic on every listener, except Hertz's, is counted and reported by the control endpoint.
+	var addrs []string
+	var conns *benchmark.ConnStats
+	if conf.wire {
+		conns = &benchmark.ConnStats{}
+	}
+	listenUncounted := func(name, address string) (net.Listener, error) {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("while listening on %s address: %w", name, err)
+		}
+		addrs = append(addrs, fmt.Sprintf("%s: %s", name, l.Addr()))
+		return l, nil
+	}
+	listen := func(name, address string) (net.Listener, error) {
+		l, err := listenUncounted(name, address)
+		if err != nil {
+			return nil, err
+		}
+		return conns.Listener(l), nil
+	}
+
+	admin, err := net.Listen("tcp", conf.admin)
+	if err != nil {
+		return fmt.Errorf("while listening on admin address: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/control/", benchmark.NewControlHandler(svc, conns))
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ready"))
+	})
+	stops = append(stops, serveHTTP(admin, &http.Server{Handler: mux}))
+
+	// The options of the -grpc and -grpc-tls servers
+	grpcOpts := h2.GRPCServerOptions()
+	if conf.keepalive != 0 {
+		grpcOpts = append(grpcOpts,
+			grpc.KeepaliveParams(keepalive.ServerParameters{Time: conf.keepalive}),
+			grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+				MinTime:             conf.keepalive,
+				PermitWithoutStream: true,
+			}))
+	}
+
+	if conf.grpc != "" {
+		l, err := listen("grpc", conf.grpc)
+		if err != nil {
+			return err
+		}
+		grpcServer := grpc.NewServer(grpcOpts...)
+		pb.RegisterRouteGuideServer(grpcServer, svc)
+		go func() {
+			if err := grpcServer.Serve(l); err != nil {
+				log.Printf("gRPC server exited: %s", err)
+			}
+		}()
+		stops = append(stops, grpcServer.GracefulStop)
+	}
+
+	if conf.grpcTuned != "" {
+		l, err := listen("grpc-tuned", conf.grpcTuned)
+		if err != nil {
+			return err
+		}
+		grpcServer := grpc.NewServer(benchmark.DefaultGRPCTuning.ServerOptions()...)
+		pb.RegisterRouteGuideServer(grpcServer, svc)
+		go func() {
+			if err := grpcServer.Serve(l); err != nil {
+				log.Printf("tuned gRPC server exited: %s", err)
+			}
+		}()
+		stops = append(stops, grpcServer.GracefulStop)
+	}
+
+	if conf.http1 != "" {
+		l, err := listen("http1", conf.http1)
+		if err != nil {
+			return err
+		}
+		stops = append(stops, serveHTTP(l, &http.Server{Handler: benchmark.NewHTTPHandler(svc)}))
+	}
+
+	if conf.h2c != "" {
+		l, err := listen("h2c", conf.h2c)
+		if err != nil {
+			return err
+		}
+		h2s := &http2.Server{}
+		h2.Server(h2s)
+		stops = append(stops, serveHTTP(l, &http.Server{
+			Handler: h2c.NewHandler(benchmark.NewHTTPHandler(svc), h2s),
+		}))
+	}
+
+	if conf.fasthttp != "" {
+		l, err := listen("fasthttp", conf.fasthttp)
+		if err != nil {
+			return err
+		}
+		srv := &fasthttp.Server{Handler: benchmark.NewFastHTTPHandler(svc)}
+		go func() {
+			if err := srv.Serve(l); err != nil {
+				log.Printf("fasthttp server exited: %s", err)
+			}
+		}()
+		stops = append(stops, func() { _ = srv.Shutdown() })
+	}
+
+	if conf.hertz != "" {
+		// Hertz serves connections with netpoll, which cannot use the counting listener
+		l, err := listenUncounted("hertz", conf.hertz)
+		if err != nil {
+			return err
+		}
+		h := benchmark.NewHertzServer(l, svc)
+		go func() {
+			if err := h.Run(); err != nil {
+				log.Printf("Hertz server exited: %s", err)
+			}
+		}()
+		stops = append(stops, func() { _ = h.Shutdown(context.Background()) })
+	}
+
+	if conf.https != "" || conf.mtls != "" || conf.grpcTLS != "" {
+		tlsConf, err := setupTLS(conf.tlsDir, tlsOptions{
+			keyType: benchmark.KeyType(conf.tlsKeyType),
+			profile: benchmark.TLSProfile(conf.tlsProfile),
+			reload:  conf.tlsReload,
+		})
+		if err != nil {
+			return err
+		}
+
+		if conf.grpcTLS != "" {
+			l, err := listen("grpc-tls", conf.grpcTLS)
+			if err != nil {
+				return err
+			}
+			grpcServer := grpc.NewServer(append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConf.ServerTLS)))...)
+			pb.RegisterRouteGuideServer(grpcServer, svc)
+			go func() {
+				if err := grpcServer.Serve(l); err != nil {
+					log.Printf("gRPC TLS server exited: %s", err)
+				}
+			}()
+			stops = append(stops, grpcServer.GracefulStop)
+		}
+
+		if conf.https != "" {
+			l, err := listen("https", conf.https)
+			if err != nil {
+				return err
+			}
+			srv := &http.Server{
+				Handler:   benchmark.NewHTTPHandler(svc),
+				TLSConfig: tlsConf.ServerTLS,
+			}
+			if err := configureHTTP2(srv, h2); err != nil {
+				return err
+			}
+			stops = append(stops, serveHTTP(l, srv))
+		}
+
+		if conf.mtls != "" {
+			mtlsConf := benchmark.TLSConfig{
+				ClientAuth:         tls.RequireAndVerifyClientCert,
+				CaPEM:              tlsConf.CaPEM,
+				CaKeyPEM:           tlsConf.CaKeyPEM,
+				CertPEM:            tlsConf.CertPEM,
+				KeyPEM:             tlsConf.KeyPEM,
+				CertFile:           tlsConf.CertFile,
+				KeyFile:            tlsConf.KeyFile,
+				CertReloadInterval: tlsConf.CertReloadInterval,
+				Profile:            tlsConf.Profile,
+			}
+			if err := benchmark.SetupTLS(&mtlsConf); err != nil {
+				return fmt.Errorf("while setting up mTLS: %w", err)
+			}
+			l, err := listen("mtls", conf.mtls)
+			if err != nil {
+				return err
+			}
+			srv := &http.Server{
+				Handler:   benchmark.NewHTTPHandler(svc),
+				TLSConfig: mtlsConf.ServerTLS,
+			}
+			if err := configureHTTP2(srv, h2); err != nil {
+				return err
+			}
+			stops = append(stops, serveHTTP(l, srv))
+		}
+	}
+
+	// Every listener is open, so connections will be accepted from now on
+	ready.Store(true)
+	for _, addr := range addrs {
+		_, _ = fmt.Fprintln(stdout, addr)
+	}
+	_, _ = fmt.Fprintf(stdout, "%s%s\n", AdminPrefix, admin.Addr())
+
+	<-ctx.Done()
+	return nil
+}
+
+// serveHTTP serves srv on the listener, with TLS if srv.TLSConfig is set, and returns a func
+// which gracefully shuts it down
+func serveHTTP(l net.Listener, srv *http.Server) func() {
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(l, "", "")
+		} else {
+			err = srv.Serve(l)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("server on '%s' exited: %s", l.Addr(), err)
+		}
+	}()
+	return func() { _ = srv.Shutdown(context.Background()) }
+}
+
+// configureHTTP2 applies the tuning to the HTTP/2 server of srv. The default HTTP/2 server of
+// net/http is kept when there is no tuning.
+func configureHTTP2(srv *http.Server, t benchmark.HTTP2Tuning) error {
+	if t == (benchmark.HTTP2Tuning{}) {
+		return nil
+	}
+	h2s := &http2.Server{}
+	t.Server(h2s)
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return fmt.Errorf("while configuring HTTP/2: %w", err)
+	}
+	return nil
+}
+
+// tlsOptions are the -tls-* flags applied to the certificates in the -tls-dir
+type tlsOptions struct {
+	// keyType is the algorithm of the generated keys
+	keyType benchmark.KeyType
+	profile benchmark.TLSProfile
+	// reload is how often the certificate is checked for changes, zero never reloads it
+	reload time.Duration
+}
+
+// setupTLS loads the certificates in dir. Certificates are only generated, and written to dir,
+// when dir has neither cert.pem nor cert.key.
+func setupTLS(dir string, opts tlsOptions) (*benchmark.TLSConfig, error) {
+	if opts.reload != 0 && dir == "" {
+		return nil, errors.New("-tls-reload requires -tls-dir")
+	}
+	conf := benchmark.TLSConfig{KeyType: opts.keyType, Profile: opts.profile}
+	missing := make(map[string]bool)
+	if dir != "" {
+		for _, f := range tlsFiles {
+			path := filepath.Join(dir, f.name)
+			if _, err := os.Stat(path); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					missing[f.name] = true
+					continue
+				}
+				return nil, fmt.Errorf("while reading '%s': %w", f.name, err)
+			}
+			*f.file(&conf) = path
+		}
+	}
+
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		return nil, fmt.Errorf("while setting up TLS: %w", err)
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("while creating '%s': %w", dir, err)
+		}
+		for _, f := range tlsFiles {
+			// The CA and its key are not needed by servers using a provided certificate
+			pem := *f.pem(&conf)
+			if !missing[f.name] || pem == nil {
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(dir, f.name), pem.Bytes(), 0600); err != nil {
+				return nil, fmt.Errorf("while writing '%s': %w", f.name, err)
+			}
+		}
+	}
+
+	// The reloader reads the files, which only exist once any generated certificates are written
+	if opts.reload != 0 {
+		conf.CertFile = filepath.Join(dir, "cert.pem")
+		conf.KeyFile = filepath.Join(dir, "cert.key")
+		conf.CertReloadInterval = opts.reload
+		if err := benchmark.SetupTLS(&conf); err != nil {
+			return nil, fmt.Errorf("while setting up TLS: %w", err)
+		}
+	}
+	return &conf, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- run(ctx, config{
+			grpc:    "localhost:0",
+			grpcTLS: "localhost:0",
+			http1:   "localhost:0",
+			h2c:     "localhost:0",
+			https:   "localhost:0",
+			mtls:    "localhost:0",
+			admin:   "localhost:0",
+			tlsDir:  dir,
+		}, w)
+	}()
+
+	addrs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, addr, _ := strings.Cut(scanner.Text(), ": ")
+		addrs[name] = addr
+		if name+": " == AdminPrefix {
+			break
+		}
+	}
+	go func() { _, _ = io.Copy(io.Discard, r) }()
+
+	resp, err := http.Get("http://" + addrs["admin"] + "/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected ready; got '%s'", resp.Status)
+	}
+
+	conn, err := grpc.Dial(addrs["grpc"], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := &pb.Point{Latitude: 409146138, Longitude: -746188906}
+	if _, err := pb.NewRouteGuideClient(conn).GetFeature(ctx, point); err != nil {
+		t.Errorf("gRPC: %s", err)
+	}
+
+	// Clients use the certificates the server generated into the -tls-dir
+	var conf benchmark.TLSConfig
+	for _, f := range tlsFiles {
+		b, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		*f.pem(&conf) = bytes.NewBuffer(b)
+	}
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		t.Fatal(err)
+	}
+	noCert := conf.ClientTLS.Clone()
+	noCert.Certificates = nil
+
+	tlsConn, err := grpc.Dial(addrs["grpc-tls"], grpc.WithTransportCredentials(credentials.NewTLS(noCert)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pb.NewRouteGuideClient(tlsConn).GetFeature(ctx, point); err != nil {
+		t.Errorf("gRPC TLS: %s", err)
+	}
+
+	h2cTransport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+
+	for _, test := range []struct {
+		name      string
+		url       string
+		transport http.RoundTripper
+		proto     string
+		wantErr   bool
+	}{
+		{name: "HTTP1", url: "http://" + addrs["http1"], transport: &http.Transport{}, proto: "HTTP/1.1"},
+		{name: "H2C", url: "http://" + addrs["h2c"], transport: h2cTransport, proto: "HTTP/2.0"},
+		{name: "HTTPS", url: "https://" + addrs["https"], transport: &http2.Transport{TLSClientConfig: noCert},
+			proto: "HTTP/2.0"},
+		{name: "MTLS", url: "https://" + addrs["mtls"], transport: &http2.Transport{TLSClientConfig: conf.ClientTLS},
+			proto: "HTTP/2.0"},
+		{name: "MTLSWithoutClientCert", url: "https://" + addrs["mtls"],
+			transport: &http2.Transport{TLSClientConfig: noCert}, wantErr: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			hc := &http.Client{Transport: test.transport}
+			client := benchmark.NewClient(hc, test.url)
+			var feature pb.Feature
+			err := client.GetFeature(ctx, point, &feature)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected the server to reject the client")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := hc.Get(test.url + "/v1/say.hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.Proto != test.proto {
+				t.Errorf("expected protocol '%s'; got '%s'", test.proto, resp.Proto)
+			}
+		})
+	}
+
+	// GracefulStop() waits for the clients to disconnect
+	_ = conn.Close()
+	_ = tlsConn.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
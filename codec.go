@@ -0,0 +1,111 @@
+package benchmark
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals and un-marshals protobuf messages. It implements encoding.Codec, so the same
+// codec can be used by the DUH clients and handlers and by gRPC.
+type Codec interface {
+	encoding.Codec
+	// MarshalAppend appends the wire format of the message to b
+	MarshalAppend(b []byte, m proto.Message) ([]byte, error)
+}
+
+var (
+	// ProtoCodec uses the reflection based protobuf-go runtime, as proto.Marshal() and the
+	// default gRPC codec do
+	ProtoCodec Codec = protoCodec{}
+	// VTProtoCodec uses the code generated by protoc-gen-go-vtproto for the messages which have
+	// it, and the protobuf-go runtime for those which do not, such as the duh-go error reply
+	VTProtoCodec Codec = vtprotoCodec{}
+)
+
+// Both codecs produce the same wire format, so they share the name of the default gRPC codec and
+// a client using one codec can call a server using the other.
+const codecName = "proto"
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return codecName }
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, err := message(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) MarshalAppend(b []byte, m proto.Message) ([]byte, error) {
+	return proto.MarshalOptions{}.MarshalAppend(b, m)
+}
+
+func (protoCodec) Unmarshal(b []byte, v interface{}) error {
+	m, err := message(v)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// vtMessage is implemented by messages generated with the protoc-gen-go-vtproto features
+// marshal, unmarshal and size
+type vtMessage interface {
+	SizeVT() int
+	MarshalToSizedBufferVT(b []byte) (int, error)
+	UnmarshalVT(b []byte) error
+}
+
+type vtprotoCodec struct{}
+
+func (vtprotoCodec) Name() string { return codecName }
+
+func (c vtprotoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, err := message(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.MarshalAppend(nil, m)
+}
+
+func (vtprotoCodec) MarshalAppend(b []byte, m proto.Message) ([]byte, error) {
+	vt, ok := m.(vtMessage)
+	if !ok {
+		return proto.MarshalOptions{}.MarshalAppend(b, m)
+	}
+	size := vt.SizeVT()
+	b = append(b, make([]byte, size)...)
+	// The generated code marshals the message backwards from the end of the buffer
+	n, err := vt.MarshalToSizedBufferVT(b[len(b)-size:])
+	if err != nil {
+		return nil, err
+	}
+	return b[:len(b)-size+n], nil
+}
+
+func (vtprotoCodec) Unmarshal(b []byte, v interface{}) error {
+	if vt, ok := v.(vtMessage); ok {
+		// proto.Unmarshal() resets the message first, UnmarshalVT() merges into it
+		if m, ok := v.(proto.Message); ok {
+			proto.Reset(m)
+		}
+		return vt.UnmarshalVT(b)
+	}
+	m, err := message(v)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+func message(v interface{}) (proto.Message, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement proto.Message", v)
+	}
+	return m, nil
+}
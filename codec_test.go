@@ -0,0 +1,264 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	duhv1 "github.com/duh-rpc/duh-go/proto/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+var codecs = []struct {
+	name  string
+	codec benchmark.Codec
+}{
+	{name: "Proto", codec: benchmark.ProtoCodec},
+	{name: "VTProto", codec: benchmark.VTProtoCodec},
+}
+
+var feature = &pb.Feature{
+	Name:     "Berkshire Valley Management Area Trail, Jefferson, NJ, USA",
+	Location: &pb.Point{Latitude: 409146138, Longitude: -746188906},
+}
+
+func TestCodec(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			b, err := c.codec.Marshal(feature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Both codecs must produce the same wire format
+			want, err := proto.Marshal(feature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != string(want) {
+				t.Errorf("expected '%x'; got '%x'", want, b)
+			}
+
+			prefix := []byte("prefix")
+			b, err = c.codec.MarshalAppend(prefix, feature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "prefix"+string(want) {
+				t.Errorf("expected the message appended to the prefix; got '%x'", b)
+			}
+
+			// Un-marshalling into a message which is reused must not keep any of its fields
+			out := &pb.Feature{Name: "stale", Location: &pb.Point{Latitude: 1}}
+			if err := c.codec.Unmarshal(want, out); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(out, feature) {
+				t.Errorf("expected '%v'; got '%v'", feature, out)
+			}
+			if err := c.codec.Unmarshal(nil, out); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(out, &pb.Feature{}) {
+				t.Errorf("expected an empty feature; got '%v'", out)
+			}
+
+			// Messages without generated code, such as the duh-go reply, use the protobuf-go runtime
+			b, err = c.codec.Marshal(&duhv1.Reply{Code: duh.CodeNotFound, Message: "not found"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reply duhv1.Reply
+			if err := c.codec.Unmarshal(b, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Code != duh.CodeNotFound || reply.Message != "not found" {
+				t.Errorf("unexpected reply '%v'", &reply)
+			}
+
+			if _, err := c.codec.Marshal("not a message"); err == nil {
+				t.Error("expected an error when marshalling a string")
+			}
+		})
+	}
+}
+
+func TestCodecTransports(t *testing.T) {
+	for _, c := range codecs {
+		t.Run("GRPC/"+c.name, func(t *testing.T) {
+			getFeature := dialCodecGRPC(t, c.codec)
+			resp, err := getFeature(context.Background(), knownPoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Name == "" {
+				t.Errorf("expected the name of the feature at '%v'", knownPoint)
+			}
+		})
+
+		t.Run("HTTP/"+c.name, func(t *testing.T) {
+			svc := server.NewRouteGuideServer()
+			srv := httptest.NewServer(benchmark.NewCodecHandler(svc, c.codec))
+			defer srv.Close()
+			ctx := context.Background()
+
+			for _, client := range []*benchmark.HTTPClient{
+				benchmark.NewClient(srv.Client(), srv.URL, benchmark.WithCodec(c.codec)),
+				// The generated client sends protobuf and receives JSON
+				benchmark.NewClient(srv.Client(), srv.URL),
+			} {
+				var resp pb.Feature
+				if err := client.GetFeature(ctx, knownPoint, &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Name == "" {
+					t.Errorf("expected the name of the feature at '%v'", knownPoint)
+				}
+
+				svc.SetErrorMode(server.ErrorModeNotFoundWithDetails)
+				err := client.GetFeature(ctx, unknownPoint, &resp)
+				svc.SetErrorMode(server.ErrorModeNone)
+				var de duh.Error
+				if !errors.As(err, &de) || de.Code() != duh.CodeNotFound || len(de.Details()) == 0 {
+					t.Errorf("expected error with code '%d' and details; got '%v'", duh.CodeNotFound, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCodec measures the serialization of the reply, which is the largest RouteGuide
+// message used by the benchmarks, with each codec
+func BenchmarkCodec(b *testing.B) {
+	for _, c := range codecs {
+		b.Run(c.name+"/Marshal", func(b *testing.B) {
+			var buf []byte
+			var err error
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if buf, err = c.codec.MarshalAppend(buf[:0], feature); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(c.name+"/Unmarshal", func(b *testing.B) {
+			buf, err := c.codec.Marshal(feature)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var out pb.Feature
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if err := c.codec.Unmarshal(buf, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCodecTransport calls GetFeature over each transport with each codec used by both the
+// client and the server, so the difference between the codecs is the share of the cost of the
+// transport spent on serialization. The server runs in the same process.
+func BenchmarkCodecTransport(b *testing.B) {
+	for _, c := range codecs {
+		b.Run("GRPC/"+c.name, func(b *testing.B) {
+			getFeature := dialCodecGRPC(b, c.codec)
+			runCodecTransport(b, func(ctx context.Context) error {
+				_, err := getFeature(ctx, knownPoint)
+				return err
+			})
+		})
+
+		for _, transport := range []struct {
+			name   string
+			server func(http.Handler) *httptest.Server
+			client func() *http.Client
+		}{
+			{
+				name:   "HTTP1",
+				server: httptest.NewServer,
+				client: func() *http.Client {
+					return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+				},
+			},
+			{
+				name: "HTTP2",
+				server: func(h http.Handler) *httptest.Server {
+					return httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+				},
+				client: func() *http.Client {
+					return &http.Client{Transport: &http2.Transport{
+						AllowHTTP: true,
+						DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+							var d net.Dialer
+							return d.DialContext(ctx, network, addr)
+						},
+					}}
+				},
+			},
+		} {
+			b.Run(transport.name+"/"+c.name, func(b *testing.B) {
+				srv := transport.server(benchmark.NewCodecHandler(server.NewRouteGuideServer(), c.codec))
+				defer srv.Close()
+				hc := transport.client()
+				defer hc.CloseIdleConnections()
+				client := benchmark.NewClient(hc, srv.URL, benchmark.WithCodec(c.codec))
+
+				var resp pb.Feature
+				runCodecTransport(b, func(ctx context.Context) error {
+					return client.GetFeature(ctx, knownPoint, &resp)
+				})
+			})
+		}
+	}
+}
+
+func runCodecTransport(b *testing.B, getFeature func(context.Context) error) {
+	ctx := context.Background()
+	if err := getFeature(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := getFeature(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// dialCodecGRPC serves the RouteGuide service over gRPC with the codec and returns a client which
+// uses the same codec. Both are stopped when the test or benchmark is done.
+func dialCodecGRPC(tb testing.TB, codec benchmark.Codec) func(context.Context, *pb.Point) (*pb.Feature, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec))
+	pb.RegisterRouteGuideServer(srv, server.NewRouteGuideServer())
+	go func() { _ = srv.Serve(l) }()
+	tb.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = conn.Close() })
+	client := pb.NewRouteGuideClient(conn)
+	return func(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
+		return client.GetFeature(ctx, point)
+	}
+}
@@ -0,0 +1,124 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+)
+
+// ConnCounts are the bytes and calls made on connections counted by ConnStats
+type ConnCounts struct {
+	BytesRead    int64 `json:"bytes_read"`
+	BytesWritten int64 `json:"bytes_written"`
+	// Reads and Writes are the number of Read() and Write() calls made on the connections, each
+	// of which is usually a single syscall.
+	Reads  int64 `json:"reads"`
+	Writes int64 `json:"writes"`
+}
+
+// Sub returns the counts between o and c
+func (c ConnCounts) Sub(o ConnCounts) ConnCounts {
+	return ConnCounts{
+		BytesRead:    c.BytesRead - o.BytesRead,
+		BytesWritten: c.BytesWritten - o.BytesWritten,
+		Reads:        c.Reads - o.Reads,
+		Writes:       c.Writes - o.Writes,
+	}
+}
+
+// ConnStats counts the bytes and calls made on every connection it wraps. Connections should
+// be wrapped below TLS so the counts reflect what is sent on the wire. A nil *ConnStats counts
+// nothing and returns connections and listeners unwrapped, so counting can be turned off without
+// changing how connections are dialed.
+type ConnStats struct {
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	reads        atomic.Int64
+	writes       atomic.Int64
+}
+
+// Counts returns the totals for all connections wrapped so far
+func (s *ConnStats) Counts() ConnCounts {
+	if s == nil {
+		return ConnCounts{}
+	}
+	return ConnCounts{
+		BytesRead:    s.bytesRead.Load(),
+		BytesWritten: s.bytesWritten.Load(),
+		Reads:        s.reads.Load(),
+		Writes:       s.writes.Load(),
+	}
+}
+
+// Conn returns a net.Conn which counts the reads and writes made on c
+func (s *ConnStats) Conn(c net.Conn) net.Conn {
+	if s == nil {
+		return c
+	}
+	return &countingConn{Conn: c, stats: s}
+}
+
+// Listener returns a net.Listener which counts the reads and writes made on accepted connections
+func (s *ConnStats) Listener(l net.Listener) net.Listener {
+	if s == nil {
+		return l
+	}
+	return &countingListener{Listener: l, stats: s}
+}
+
+// DialContext dials a connection which counts its reads and writes
+func (s *ConnStats) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Conn(c), nil
+}
+
+// DialTLSContext dials a TLS connection, counting the reads and writes of the underlying connection
+func (s *ConnStats) DialTLSContext(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	c, err := s.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := tls.Client(c, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+type countingConn struct {
+	net.Conn
+	stats *ConnStats
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.reads.Add(1)
+	c.stats.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.writes.Add(1)
+	c.stats.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+type countingListener struct {
+	net.Listener
+	stats *ConnStats
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.stats.Conn(c), nil
+}
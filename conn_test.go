@@ -0,0 +1,61 @@
+package benchmark_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+func TestConnStats(t *testing.T) {
+	var server, client benchmark.ConnStats
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = server.Listener(l)
+	defer func() { _ = l.Close() }()
+
+	done := make(chan error)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer func() { _ = c.Close() }()
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(c, b); err != nil {
+			done <- err
+			return
+		}
+		_, err = c.Write([]byte("world!"))
+		done <- err
+	}()
+
+	c, err := client.DialContext(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(c, make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	got := client.Counts()
+	if got.BytesWritten != 5 || got.BytesRead != 6 || got.Writes != 1 || got.Reads == 0 {
+		t.Errorf("unexpected client counts '%+v'", got)
+	}
+	got = server.Counts()
+	if got.BytesWritten != 6 || got.BytesRead != 5 || got.Writes != 1 || got.Reads == 0 {
+		t.Errorf("unexpected server counts '%+v'", got)
+	}
+}
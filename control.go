@@ -0,0 +1,146 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+)
+
+const (
+	controlScenario  = "/control/scenario"
+	controlStats     = "/control/stats"
+	controlFootprint = "/control/footprint"
+)
+
+// Scenario configures how the RouteGuideService responds to the calls of a benchmark scenario
+type Scenario struct {
+	ErrorMode server.ErrorMode `json:"error_mode"`
+	Latency   time.Duration    `json:"latency"`
+	ReplySize int              `json:"reply_size"`
+}
+
+// ServerStats are the resources consumed by the process running the RouteGuideService, the
+// traffic on the server's connections and the number of calls the service stopped working
+// on because the client went away.
+type ServerStats struct {
+	Usage    Usage      `json:"usage"`
+	Conn     ConnCounts `json:"conn"`
+	Canceled int64      `json:"canceled"`
+}
+
+// NewControlHandler returns a handler which allows a benchmark to configure and measure a
+// RouteGuideService running in another process. It should be served on its own listener so
+// control requests do not share connections with the transport under test. If conns is not
+// nil, its counts are included in the ServerStats.
+func NewControlHandler(service *server.RouteGuideService, conns *ConnStats) http.Handler {
+	return &controlHandler{service: service, conns: conns}
+}
+
+type controlHandler struct {
+	service *server.RouteGuideService
+	conns   *ConnStats
+}
+
+func (h *controlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case controlScenario:
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var s Scenario
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "while decoding scenario: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.service.SetErrorMode(s.ErrorMode)
+		h.service.SetLatency(s.Latency)
+		h.service.SetReplySize(s.ReplySize)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case controlStats:
+		stats := ServerStats{Usage: ReadUsage(), Canceled: h.service.CanceledCount()}
+		if h.conns != nil {
+			stats.Conn = h.conns.Counts()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(stats)
+		return
+	case controlFootprint:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ReadFootprint())
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// ControlClient configures and measures a RouteGuideService served by NewControlHandler()
+type ControlClient struct {
+	client   *http.Client
+	endpoint string
+}
+
+func NewControlClient(client *http.Client, endpoint string) *ControlClient {
+	return &ControlClient{client: client, endpoint: endpoint}
+}
+
+// SetScenario configures the service for the next benchmark scenario
+func (c *ControlClient) SetScenario(ctx context.Context, s Scenario) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+controlScenario, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("while setting scenario: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("while setting scenario: unexpected status '%s'", resp.Status)
+	}
+	return nil
+}
+
+// Stats returns the resources consumed by the server process so far
+func (c *ControlClient) Stats(ctx context.Context) (ServerStats, error) {
+	var stats ServerStats
+	if err := c.get(ctx, controlStats, &stats); err != nil {
+		return stats, fmt.Errorf("while fetching server stats: %w", err)
+	}
+	return stats, nil
+}
+
+// Footprint returns the memory and goroutines currently held by the server process
+func (c *ControlClient) Footprint(ctx context.Context) (Footprint, error) {
+	var f Footprint
+	if err := c.get(ctx, controlFootprint, &f); err != nil {
+		return f, fmt.Errorf("while fetching server footprint: %w", err)
+	}
+	return f, nil
+}
+
+func (c *ControlClient) get(ctx context.Context, path string, v any) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status '%s'", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
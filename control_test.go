@@ -0,0 +1,48 @@
+package benchmark_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+)
+
+func TestControl(t *testing.T) {
+	svc := server.NewRouteGuideServer()
+	srv := httptest.NewServer(benchmark.NewControlHandler(svc, nil))
+	defer srv.Close()
+	client := benchmark.NewControlClient(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	err := client.SetScenario(ctx, benchmark.Scenario{ErrorMode: server.ErrorModeNotFound, Latency: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := svc.FindFeature(ctx, knownPoint); err == nil {
+		t.Fatal("expected the configured latency to exceed the deadline")
+	}
+
+	stats, err := client.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Canceled != 1 {
+		t.Errorf("expected '1' canceled call; got '%d'", stats.Canceled)
+	}
+	if stats.Usage.Mallocs == 0 || stats.Usage.TotalAlloc == 0 {
+		t.Errorf("expected server usage to include allocations; got '%+v'", stats.Usage)
+	}
+
+	footprint, err := client.Footprint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footprint.Goroutines == 0 || footprint.HeapInuse == 0 {
+		t.Errorf("expected server footprint to include goroutines and heap; got '%+v'", footprint)
+	}
+}
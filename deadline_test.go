@@ -0,0 +1,154 @@
+package benchmark_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// serverLatency is long enough that a test which waits for it to expire will time out
+const serverLatency = time.Minute
+
+func TestDeadlinePropagation(t *testing.T) {
+	for _, tr := range []struct {
+		name  string
+		start func(t *testing.T) (*server.RouteGuideService, func(context.Context, *pb.Point) error)
+	}{
+		{name: "grpc", start: startGRPC},
+		{name: "http", start: startHTTP},
+	} {
+		t.Run(tr.name, func(t *testing.T) {
+			t.Run("DeadlineExceeded", func(t *testing.T) {
+				svc, getFeature := tr.start(t)
+				svc.SetLatency(serverLatency)
+
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				if err := getFeature(ctx, knownPoint); !isDeadlineExceeded(ctx, err) {
+					t.Fatalf("expected deadline exceeded; got: %v", err)
+				}
+				waitForCanceled(t, svc, 1)
+			})
+
+			t.Run("Canceled", func(t *testing.T) {
+				svc, getFeature := tr.start(t)
+				svc.SetLatency(serverLatency)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+
+				if err := getFeature(ctx, knownPoint); err == nil {
+					t.Fatal("expected canceled call to return an error")
+				}
+				waitForCanceled(t, svc, 1)
+			})
+		})
+	}
+}
+
+func TestHTTPTimeoutHeader(t *testing.T) {
+	svc := server.NewRouteGuideServer()
+	svc.SetLatency(serverLatency)
+	srv := httptest.NewServer(benchmark.NewHTTPHandler(svc))
+	defer srv.Close()
+
+	t.Run("ServerEnforcesDeadline", func(t *testing.T) {
+		// Without a deadline on the client context, only the header can stop the server
+		req, err := http.NewRequest(http.MethodPost, srv.URL+pb.RouteGuideGetFeaturePath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", duh.ContentTypeProtoBuf)
+		req.Header.Set(benchmark.HeaderTimeout, (100 * time.Millisecond).String())
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != benchmark.CodeDeadlineExceeded {
+			t.Fatalf("expected status '%d'; got '%d'", benchmark.CodeDeadlineExceeded, resp.StatusCode)
+		}
+		waitForCanceled(t, svc, 1)
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+pb.RouteGuideGetFeaturePath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(benchmark.HeaderTimeout, "forever")
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != duh.CodeBadRequest {
+			t.Fatalf("expected status '%d'; got '%d'", duh.CodeBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+// startGRPC starts a gRPC server on an ephemeral port and returns the service and a client func
+func startGRPC(t *testing.T) (*server.RouteGuideService, func(context.Context, *pb.Point) error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := server.NewRouteGuideServer()
+	grpcServer := grpc.NewServer()
+	pb.RegisterRouteGuideServer(grpcServer, svc)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client := pb.NewRouteGuideClient(conn)
+
+	return svc, func(ctx context.Context, point *pb.Point) error {
+		_, err := client.GetFeature(ctx, point)
+		return err
+	}
+}
+
+// startHTTP starts an HTTP/1 server on an ephemeral port and returns the service and a client func
+func startHTTP(t *testing.T) (*server.RouteGuideService, func(context.Context, *pb.Point) error) {
+	svc := server.NewRouteGuideServer()
+	srv := httptest.NewServer(benchmark.NewHTTPHandler(svc))
+	t.Cleanup(srv.Close)
+
+	client := benchmark.NewClient(srv.Client(), srv.URL)
+	return svc, func(ctx context.Context, point *pb.Point) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, point, &resp)
+	}
+}
+
+// waitForCanceled waits until the service reports it stopped work on the expected number of calls
+func waitForCanceled(t *testing.T, svc *server.RouteGuideService, expected int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if svc.CanceledCount() == expected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected '%d' canceled calls on the server; got '%d'", expected, svc.CanceledCount())
+}
@@ -0,0 +1,96 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	"github.com/duh-rpc/duh-go-benchmarks/v1"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewFastHTTPHandler serves the RouteGuide service over DUH-RPC with fasthttp. Since fasthttp does
+// not notice when the client goes away, the server only stops working on an abandoned request
+// once the timeout the client sent has expired.
+func NewFastHTTPHandler(service *server.RouteGuideService) fasthttp.RequestHandler {
+	h := newWireHandler(service, ProtoCodec)
+	return func(ctx *fasthttp.RequestCtx) {
+		// RequestCtx is not a standard context, so a timeout derived from it would start a goroutine
+		// per request to watch RequestCtx.Done(). It is not canceled when the client goes away anyway.
+		r := h.serve(context.Background(), wireRequest{
+			method:      ctx.Method(),
+			path:        ctx.Path(),
+			contentType: ctx.Request.Header.ContentType(),
+			accept:      ctx.Request.Header.Peek("Accept"),
+			timeout:     ctx.Request.Header.Peek(HeaderTimeout),
+			body:        ctx.PostBody(),
+		}, ctx.Response.Body()[:0])
+		ctx.SetStatusCode(r.code)
+		ctx.SetContentType(r.contentType)
+		// The reply was appended to the body buffer of the response, so swap it back in
+		ctx.Response.SwapBody(r.body)
+	}
+}
+
+// FastHTTPClient is a DUH-RPC client for the RouteGuide service which uses fasthttp. It asks for
+// protobuf replies and propagates the deadline of the context to the server. Only the deadline
+// of the context is honored, fasthttp has no way to abandon a request when it is canceled.
+type FastHTTPClient struct {
+	client *fasthttp.Client
+	// The URL is parsed once for the errors duh-go builds, and the string is what fasthttp sends
+	getFeatureURL    *url.URL
+	getFeatureRawURL string
+}
+
+func NewFastHTTPClient(client *fasthttp.Client, endpoint string) (*FastHTTPClient, error) {
+	u, err := url.Parse(endpoint + v1.RouteGuideGetFeaturePath)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing endpoint '%s': %w", endpoint, err)
+	}
+	return &FastHTTPClient{client: client, getFeatureURL: u, getFeatureRawURL: u.String()}, nil
+}
+
+func (c *FastHTTPClient) GetFeature(ctx context.Context, req *v1.Point, resp *v1.Feature) error {
+	timeout, err := wireTimeout(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, w := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(r)
+		fasthttp.ReleaseResponse(w)
+	}()
+
+	payload, err := proto.MarshalOptions{}.MarshalAppend(r.Body()[:0], req)
+	if err != nil {
+		return duh.NewClientError(fmt.Errorf("while marshaling request payload: %w", err), nil)
+	}
+	r.SwapBody(payload)
+	r.SetRequestURI(c.getFeatureRawURL)
+	r.Header.SetMethod(http.MethodPost)
+	r.Header.SetContentType(duh.ContentTypeProtoBuf)
+	r.Header.Set("Accept", duh.ContentTypeProtoBuf)
+	if timeout != "" {
+		r.Header.Set(HeaderTimeout, timeout)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = c.client.DoDeadline(r, w, deadline)
+	} else {
+		err = c.client.Do(r, w)
+	}
+	if err != nil {
+		return transportError(err, errors.Is(err, fasthttp.ErrTimeout), map[string]string{
+			duh.DetailsHttpUrl:    c.getFeatureRawURL,
+			duh.DetailsHttpMethod: http.MethodPost,
+		})
+	}
+	return decodeReply(ProtoCodec, http.MethodPost, c.getFeatureURL, w.StatusCode(),
+		string(w.Header.ContentType()), w.Body(), resp)
+}
@@ -3,16 +3,35 @@ module github.com/duh-rpc/duh-go-benchmarks
 go 1.21.0
 
 require (
+	github.com/cloudwego/hertz v0.10.4
+	github.com/duh-rpc/duh-go v0.0.2-0.20230929155108-5d641b0c008a
 	github.com/golang/protobuf v1.5.3
-	google.golang.org/grpc v1.58.0
-	google.golang.org/grpc/examples v0.0.0-20230912205319-2d1bb21e4dc9
-	google.golang.org/protobuf v1.31.0
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904
+	github.com/planetscale/vtprotobuf v0.6.0
+	github.com/valyala/fasthttp v1.51.0
+	golang.org/x/net v0.24.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d
+	google.golang.org/grpc v1.58.2
+	google.golang.org/protobuf v1.34.1
 )
 
 require (
-	github.com/duh-rpc/duh-go v0.0.2-0.20230929155108-5d641b0c008a // indirect
-	golang.org/x/net v0.15.0 // indirect
-	golang.org/x/sys v0.12.0 // indirect
-	golang.org/x/text v0.13.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/bytedance/gopkg v0.1.3 // indirect
+	github.com/bytedance/sonic v1.15.0 // indirect
+	github.com/bytedance/sonic/loader v0.5.0 // indirect
+	github.com/cloudwego/base64x v0.1.6 // indirect
+	github.com/cloudwego/gopkg v0.1.4 // indirect
+	github.com/cloudwego/netpoll v0.7.2 // indirect
+	github.com/fsnotify/fsnotify v1.5.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.9 // indirect
+	github.com/tidwall/gjson v1.14.4 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/arch v0.0.0-20210923205945-b76863e36670 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,82 @@
+package benchmark
+
+import (
+	"runtime"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCTuning are the buffer, worker and codec settings of a tuned gRPC server and client. The
+// zero value leaves every setting at the gRPC default.
+type GRPCTuning struct {
+	// ReadBufferSize and WriteBufferSize are the sizes of the buffers of each connection, zero
+	// uses the gRPC default of 32KiB
+	ReadBufferSize  int
+	WriteBufferSize int
+	// SharedWriteBuffer releases the write buffer of a connection to a pool after each flush,
+	// instead of keeping it for the life of the connection
+	SharedWriteBuffer bool
+	// RecvBufferPool reuses the buffers messages are received into
+	RecvBufferPool bool
+	// NumStreamWorkers serves streams with a fixed pool of goroutines instead of a goroutine per
+	// stream, zero uses a goroutine per stream
+	NumStreamWorkers uint32
+	// Codec marshals and un-marshals the messages, nil uses the default gRPC codec
+	Codec Codec
+}
+
+// DefaultGRPCTuning is the tuning used by BenchmarkGRPCTuned and `routeguide-server -grpc-tuned`
+var DefaultGRPCTuning = GRPCTuning{
+	ReadBufferSize:    64 * 1024,
+	WriteBufferSize:   64 * 1024,
+	SharedWriteBuffer: true,
+	RecvBufferPool:    true,
+	NumStreamWorkers:  uint32(runtime.NumCPU()),
+	Codec:             VTProtoCodec,
+}
+
+// ServerOptions returns the options which apply the tuning to a grpc.Server
+func (t GRPCTuning) ServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if t.ReadBufferSize != 0 {
+		opts = append(opts, grpc.ReadBufferSize(t.ReadBufferSize))
+	}
+	if t.WriteBufferSize != 0 {
+		opts = append(opts, grpc.WriteBufferSize(t.WriteBufferSize))
+	}
+	if t.SharedWriteBuffer {
+		opts = append(opts, grpc.SharedWriteBuffer(true))
+	}
+	if t.RecvBufferPool {
+		opts = append(opts, grpc.RecvBufferPool(grpc.NewSharedBufferPool()))
+	}
+	if t.NumStreamWorkers != 0 {
+		opts = append(opts, grpc.NumStreamWorkers(t.NumStreamWorkers))
+	}
+	if t.Codec != nil {
+		opts = append(opts, grpc.ForceServerCodec(t.Codec))
+	}
+	return opts
+}
+
+// DialOptions returns the options which apply the tuning to a grpc.ClientConn. NumStreamWorkers
+// only applies to the server.
+func (t GRPCTuning) DialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if t.ReadBufferSize != 0 {
+		opts = append(opts, grpc.WithReadBufferSize(t.ReadBufferSize))
+	}
+	if t.WriteBufferSize != 0 {
+		opts = append(opts, grpc.WithWriteBufferSize(t.WriteBufferSize))
+	}
+	if t.SharedWriteBuffer {
+		opts = append(opts, grpc.WithSharedWriteBuffer(true))
+	}
+	if t.RecvBufferPool {
+		opts = append(opts, grpc.WithRecvBufferPool(grpc.NewSharedBufferPool()))
+	}
+	if t.Codec != nil {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(t.Codec)))
+	}
+	return opts
+}
@@ -0,0 +1,67 @@
+package benchmark_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCTuning(t *testing.T) {
+	var zero benchmark.GRPCTuning
+	if len(zero.ServerOptions()) != 0 || len(zero.DialOptions()) != 0 {
+		t.Errorf("expected no options for the zero value")
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := server.NewRouteGuideServer()
+	srv := grpc.NewServer(benchmark.DefaultGRPCTuning.ServerOptions()...)
+	pb.RegisterRouteGuideServer(srv, svc)
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	// The tuned server must be compatible with default clients, and the tuned client with itself
+	for _, c := range []struct {
+		name string
+		opts []grpc.DialOption
+	}{
+		{name: "Default"},
+		{name: "Tuned", opts: benchmark.DefaultGRPCTuning.DialOptions()},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := grpc.Dial(l.Addr().String(),
+				append(c.opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = conn.Close() }()
+			client := pb.NewRouteGuideClient(conn)
+			ctx := context.Background()
+
+			resp, err := client.GetFeature(ctx, knownPoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Name == "" {
+				t.Errorf("expected the name of the feature at '%v'", knownPoint)
+			}
+
+			svc.SetErrorMode(server.ErrorModeNotFoundWithDetails)
+			defer svc.SetErrorMode(server.ErrorModeNone)
+			_, err = client.GetFeature(ctx, unknownPoint)
+			if s := status.Convert(err); s.Code() != codes.NotFound || len(s.Details()) == 0 {
+				t.Errorf("expected NotFound with details; got '%v'", err)
+			}
+		})
+	}
+}
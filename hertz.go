@@ -0,0 +1,115 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/client"
+	hzserver "github.com/cloudwego/hertz/pkg/app/server"
+	errs "github.com/cloudwego/hertz/pkg/common/errors"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	"github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewHertzServer returns a Hertz server which serves the RouteGuide service on the listener once it
+// is Run(). Hertz serves connections with netpoll, which only accepts the listener returned by
+// net.Listen(), so the listener cannot be wrapped by ConnStats.Listener().
+func NewHertzServer(l net.Listener, service *server.RouteGuideService) *hzserver.Hertz {
+	hlog.SetLevel(hlog.LevelWarn)
+	h := hzserver.New(
+		hzserver.WithListener(l),
+		hzserver.WithDisablePrintRoute(true),
+		// Cancel the context of a request when the client goes away, as net/http does
+		hzserver.WithSenseClientDisconnection(true),
+		// The server is only stopped once the benchmark is done with it
+		hzserver.WithExitWaitTime(0),
+	)
+	h.Any("/*path", NewHertzHandler(service))
+	return h
+}
+
+// NewHertzHandler serves the RouteGuide service over DUH-RPC with Hertz
+func NewHertzHandler(service *server.RouteGuideService) app.HandlerFunc {
+	h := newWireHandler(service, ProtoCodec)
+	return func(ctx context.Context, c *app.RequestContext) {
+		buf := c.Response.BodyBuffer()
+		r := h.serve(ctx, wireRequest{
+			method:      c.Method(),
+			path:        c.Path(),
+			contentType: c.Request.Header.ContentType(),
+			accept:      c.Request.Header.Peek("Accept"),
+			timeout:     c.Request.Header.Peek(HeaderTimeout),
+			body:        c.Request.Body(),
+		}, buf.B[:0])
+		buf.B = r.body
+		c.SetStatusCode(r.code)
+		c.SetContentType(r.contentType)
+	}
+}
+
+// HertzClient is a DUH-RPC client for the RouteGuide service which uses the Hertz client. It asks
+// for protobuf replies and propagates the deadline of the context to the server.
+type HertzClient struct {
+	client *client.Client
+	// The URL is parsed once for the errors duh-go builds, and the string is what Hertz sends
+	getFeatureURL    *url.URL
+	getFeatureRawURL string
+}
+
+func NewHertzClient(c *client.Client, endpoint string) (*HertzClient, error) {
+	u, err := url.Parse(endpoint + v1.RouteGuideGetFeaturePath)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing endpoint '%s': %w", endpoint, err)
+	}
+	return &HertzClient{client: c, getFeatureURL: u, getFeatureRawURL: u.String()}, nil
+}
+
+func (c *HertzClient) GetFeature(ctx context.Context, req *v1.Point, resp *v1.Feature) error {
+	timeout, err := wireTimeout(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, w := protocol.AcquireRequest(), protocol.AcquireResponse()
+	defer func() {
+		protocol.ReleaseRequest(r)
+		protocol.ReleaseResponse(w)
+	}()
+
+	buf := r.BodyBuffer()
+	buf.B, err = proto.MarshalOptions{}.MarshalAppend(buf.B[:0], req)
+	if err != nil {
+		return duh.NewClientError(fmt.Errorf("while marshaling request payload: %w", err), nil)
+	}
+	r.SetRequestURI(c.getFeatureRawURL)
+	r.SetMethod(http.MethodPost)
+	r.Header.SetContentTypeBytes([]byte(duh.ContentTypeProtoBuf))
+	r.Header.Set("Accept", duh.ContentTypeProtoBuf)
+	if timeout != "" {
+		r.Header.Set(HeaderTimeout, timeout)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = c.client.DoDeadline(ctx, r, w, deadline)
+	} else {
+		err = c.client.Do(ctx, r, w)
+	}
+	if err != nil {
+		timedOut := errors.Is(err, errs.ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+		return transportError(err, timedOut, map[string]string{
+			duh.DetailsHttpUrl:    c.getFeatureRawURL,
+			duh.DetailsHttpMethod: http.MethodPost,
+		})
+	}
+	return decodeReply(ProtoCodec, http.MethodPost, c.getFeatureURL, w.StatusCode(),
+		string(w.Header.ContentType()), w.Body(), resp)
+}
@@ -0,0 +1,83 @@
+package benchmark
+
+import (
+	"fmt"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// HTTP2Tuning are the flow control, frame size and write scheduler settings of the HTTP/2
+// transports. The zero value leaves every setting at the default of x/net/http2 or gRPC.
+//
+// http2.Transport has no flow control settings, it always advertises a window of 4MiB per
+// stream and 1GiB per connection. So for H2C and HTTPS the windows only limit the request bodies
+// read by the server and have no effect on replies, while for gRPC they apply to both the client
+// and the server. Setting either window disables the BDP estimation gRPC otherwise uses to grow
+// its windows.
+type HTTP2Tuning struct {
+	// StreamWindow is the initial flow control window of each stream
+	StreamWindow int32
+	// ConnWindow is the initial flow control window of each connection
+	ConnWindow int32
+	// MaxFrameSize is the largest frame the client and the server are willing to read. gRPC
+	// always uses 16KiB frames, so it does not apply to gRPC.
+	MaxFrameSize uint32
+	// WriteScheduler is the http2.Server write scheduler; 'priority' (the default) or 'random'.
+	// gRPC has its own scheduler, so it does not apply to gRPC.
+	WriteScheduler string
+}
+
+// WriteSchedulers are the names accepted by HTTP2Tuning.WriteScheduler
+var WriteSchedulers = []string{"priority", "random"}
+
+// Validate returns an error if the write scheduler is unknown
+func (t HTTP2Tuning) Validate() error {
+	switch t.WriteScheduler {
+	case "", "priority", "random":
+		return nil
+	}
+	return fmt.Errorf("unknown write scheduler '%s'; expected one of %v", t.WriteScheduler, WriteSchedulers)
+}
+
+// Server applies the tuning to an http2.Server
+func (t HTTP2Tuning) Server(s *http2.Server) {
+	s.MaxUploadBufferPerStream = t.StreamWindow
+	s.MaxUploadBufferPerConnection = t.ConnWindow
+	s.MaxReadFrameSize = t.MaxFrameSize
+	switch t.WriteScheduler {
+	case "random":
+		s.NewWriteScheduler = http2.NewRandomWriteScheduler
+	case "priority":
+		s.NewWriteScheduler = func() http2.WriteScheduler { return http2.NewPriorityWriteScheduler(nil) }
+	}
+}
+
+// Transport applies the tuning to an http2.Transport
+func (t HTTP2Tuning) Transport(tr *http2.Transport) {
+	tr.MaxReadFrameSize = t.MaxFrameSize
+}
+
+// GRPCServerOptions returns the options which apply the tuning to a grpc.Server
+func (t HTTP2Tuning) GRPCServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if t.StreamWindow != 0 {
+		opts = append(opts, grpc.InitialWindowSize(t.StreamWindow))
+	}
+	if t.ConnWindow != 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(t.ConnWindow))
+	}
+	return opts
+}
+
+// GRPCDialOptions returns the options which apply the tuning to a grpc.ClientConn
+func (t HTTP2Tuning) GRPCDialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if t.StreamWindow != 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(t.StreamWindow))
+	}
+	if t.ConnWindow != 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(t.ConnWindow))
+	}
+	return opts
+}
@@ -3,9 +3,13 @@ package benchmark
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/duh-rpc/duh-go"
 	"github.com/duh-rpc/duh-go-benchmarks/v1"
@@ -16,32 +20,218 @@ var bufferPool = sync.Pool{
 	New: func() interface{} { return new(bytes.Buffer) },
 }
 
+// HTTPClient is the generated DUH-RPC client for the RouteGuide service, which also propagates
+// the deadline of the context to the server and optionally retries failed calls.
 type HTTPClient struct {
-	*duh.Client
-	endpoint string
+	*v1.RouteGuideDUHClient
+	retry *RetryPolicy
+
+	// The low allocation path used when the client is created WithPooling() or WithCodec()
+	pooled        bool
+	codec         Codec
+	client        *http.Client
+	getFeatureURL *url.URL
 }
 
-func NewClient(client *http.Client, endpoint string) *HTTPClient {
-	return &HTTPClient{
-		endpoint: endpoint,
-		Client: &duh.Client{
-			Client: client,
-		},
+// ClientOption configures an HTTPClient created by NewClient()
+type ClientOption func(*HTTPClient)
+
+// WithRetryPolicy retries failed calls according to the policy
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *HTTPClient) {
+		c.retry = &p
 	}
 }
 
+// WithPooling sends requests with pooled requests and buffers and a URL which is parsed once,
+// instead of the generated client which allocates them on every call. The generated client sends
+// no Accept header, so the server replies with JSON; the pooled client asks for protobuf.
+func WithPooling() ClientOption {
+	return func(c *HTTPClient) {
+		c.pooled = true
+	}
+}
+
+// WithCodec marshals requests and un-marshals replies with the codec. The codec is only used by
+// the pooled path, so WithPooling() is implied.
+func WithCodec(codec Codec) ClientOption {
+	return func(c *HTTPClient) {
+		c.pooled = true
+		c.codec = codec
+	}
+}
+
+func NewClient(client *http.Client, endpoint string, opts ...ClientOption) *HTTPClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &HTTPClient{
+		RouteGuideDUHClient: v1.NewRouteGuideDUHClient(v1.RouteGuideDUHClientConfig{
+			Endpoint: endpoint,
+			Client:   client,
+			Prepare:  propagateDeadline,
+		}),
+		codec:  ProtoCodec,
+		client: client,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	// If the endpoint is invalid, the generated client reports the error on every call
+	if u, err := url.Parse(endpoint + v1.RouteGuideGetFeaturePath); err == nil && c.pooled {
+		c.getFeatureURL = u
+	}
+	return c
+}
+
 func (c *HTTPClient) GetFeature(ctx context.Context, req *v1.Point, resp *v1.Feature) error {
-	payload, err := proto.Marshal(req)
+	if c.retry == nil {
+		return c.getFeature(ctx, req, resp)
+	}
+	return c.retry.Do(ctx, func(ctx context.Context) error {
+		return c.getFeature(ctx, req, resp)
+	})
+}
+
+func (c *HTTPClient) getFeature(ctx context.Context, req *v1.Point, resp *v1.Feature) error {
+	if c.getFeatureURL == nil {
+		return generatedError(ctx, c.RouteGuideDUHClient.GetFeature(ctx, req, resp))
+	}
+	return c.doPooled(ctx, c.getFeatureURL, req, resp)
+}
+
+// generatedError returns CodeDeadlineExceeded for calls of the generated client which failed
+// because their deadline expired. duh.Client wraps the error of the transport in a
+// duh.ClientError which does not unwrap, so the context of the call identifies the cause.
+func generatedError(ctx context.Context, err error) error {
+	var ce *duh.ClientError
+	if !errors.As(err, &ce) || (ce.Code() != duh.CodeClientError && ce.Code() != duh.CodeTransportError) {
+		return err
+	}
+	if !deadlineExpired(ctx) {
+		return err
+	}
+	return duh.NewServiceError(CodeDeadlineExceeded, err, ce.Details())
+}
+
+// deadlineExpired returns true if the deadline of the context has passed, which may happen
+// before the context reports DeadlineExceeded
+func deadlineExpired(ctx context.Context) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// protoContentType is shared by every pooled request, the transports do not modify the header
+var protoContentType = []string{duh.ContentTypeProtoBuf}
+
+// pooledRequest is a request and the payload it sends, which are reused once the transport is
+// done with the body
+type pooledRequest struct {
+	req     http.Request
+	header  http.Header
+	payload []byte
+	body    requestBody
+}
+
+// requestBody records when the transport closes it, after which the payload is no longer read
+type requestBody struct {
+	bytes.Reader
+	closed atomic.Bool
+}
+
+func (b *requestBody) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+var requestPool = sync.Pool{
+	New: func() interface{} {
+		return &pooledRequest{header: make(http.Header, 2)}
+	},
+}
+
+// doPooled behaves like the generated client, but marshals the request into a pooled buffer and
+// reads the response into one
+func (c *HTTPClient) doPooled(ctx context.Context, u *url.URL, in, out proto.Message) error {
+	p := requestPool.Get().(*pooledRequest)
+
+	var err error
+	p.payload, err = c.codec.MarshalAppend(p.payload[:0], in)
 	if err != nil {
 		return duh.NewClientError(fmt.Errorf("while marshaling request payload: %w", err), nil)
 	}
+	p.body.Reset(p.payload)
+	p.body.closed.Store(false)
+	clear(p.header)
+	p.header["Content-Type"] = protoContentType
+	p.header["Accept"] = protoContentType
+	p.req = http.Request{
+		Method:        http.MethodPost,
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        p.header,
+		Body:          &p.body,
+		ContentLength: int64(len(p.payload)),
+		Host:          u.Host,
+	}
+	// WithContext is the only way to set the context of a request, and copies it
+	r := p.req.WithContext(ctx)
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		fmt.Sprintf("%s/%s", c.endpoint, "v1/route.getFeature"), bytes.NewReader(payload))
+	if err := propagateDeadline(ctx, r); err != nil {
+		requestPool.Put(p)
+		return err
+	}
+	err = c.do(r, out)
+
+	// A transport may still read the body after it returns if the server replied before the
+	// request was sent, so the request is only reused once the transport is done with it
+	if p.body.closed.Load() && p.body.Len() == 0 {
+		requestPool.Put(p)
+	}
+	return err
+}
+
+// do sends the request and un-marshals the response as duh.Client.Do() does
+func (c *HTTPClient) do(r *http.Request, out proto.Message) error {
+	resp, err := c.client.Do(r)
 	if err != nil {
-		return duh.NewClientError(err, nil)
+		timedOut := errors.Is(err, context.DeadlineExceeded) || deadlineExpired(r.Context())
+		return transportError(err, timedOut, map[string]string{
+			duh.DetailsHttpUrl:    r.URL.String(),
+			duh.DetailsHttpMethod: r.Method,
+		})
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		timedOut := errors.Is(err, context.DeadlineExceeded) || deadlineExpired(r.Context())
+		return transportError(fmt.Errorf("while reading response body: %w", err), timedOut, map[string]string{
+			duh.DetailsHttpUrl:    r.URL.String(),
+			duh.DetailsHttpMethod: r.Method,
+			duh.DetailsHttpStatus: resp.Status,
+		})
 	}
+	return decodeReply(c.codec, r.Method, r.URL, resp.StatusCode, resp.Header.Get("Content-Type"), buf.Bytes(), out)
+}
 
-	r.Header.Set("Content-Type", duh.ContentTypeProtoBuf)
-	return c.Do(r, resp)
+// propagateDeadline sends the deadline of the context to the server, just as gRPC does with the
+// `grpc-timeout` header
+func propagateDeadline(ctx context.Context, r *http.Request) error {
+	timeout, err := wireTimeout(ctx)
+	if err != nil {
+		return err
+	}
+	if timeout != "" {
+		r.Header.Set(HeaderTimeout, timeout)
+	}
+	return nil
 }
@@ -0,0 +1,213 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestPooledClient(t *testing.T) {
+	svc := server.NewRouteGuideServer()
+	srv := httptest.NewServer(benchmark.NewHTTPHandler(svc))
+	defer srv.Close()
+
+	generated := benchmark.NewClient(srv.Client(), srv.URL)
+	pooled := benchmark.NewClient(srv.Client(), srv.URL, benchmark.WithPooling())
+	ctx := context.Background()
+
+	t.Run("GetFeature", func(t *testing.T) {
+		var want pb.Feature
+		if err := generated.GetFeature(ctx, knownPoint, &want); err != nil {
+			t.Fatal(err)
+		}
+		// Reuse the pooled request several times to catch state leaking between calls
+		for i := 0; i < 3; i++ {
+			var got pb.Feature
+			if err := pooled.GetFeature(ctx, knownPoint, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != want.Name || got.Location.Latitude != want.Location.Latitude {
+				t.Fatalf("expected feature '%s'; got '%s'", want.Name, got.Name)
+			}
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		svc.SetErrorMode(server.ErrorModeNotFoundWithDetails)
+		defer svc.SetErrorMode(server.ErrorModeNone)
+
+		var resp pb.Feature
+		want := generated.GetFeature(ctx, unknownPoint, &resp)
+		got := pooled.GetFeature(ctx, unknownPoint, &resp)
+		var wantErr, gotErr duh.Error
+		if !errors.As(want, &wantErr) || !errors.As(got, &gotErr) {
+			t.Fatalf("expected duh.Error; got '%v' and '%v'", want, got)
+		}
+		if gotErr.Code() != duh.CodeNotFound || gotErr.Message() != wantErr.Message() {
+			t.Errorf("expected '%s'; got '%s'", want, got)
+		}
+		if len(gotErr.Details()) != len(wantErr.Details()) {
+			t.Errorf("expected details '%v'; got '%v'", wantErr.Details(), gotErr.Details())
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		// The timeout header of a call must not be sent by the next call which reuses the request
+		var headers []string
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = append(headers, r.Header.Get(benchmark.HeaderTimeout))
+			benchmark.NewHTTPHandler(svc).ServeHTTP(w, r)
+		}))
+		defer hs.Close()
+		client := benchmark.NewClient(hs.Client(), hs.URL, benchmark.WithPooling())
+
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		var resp pb.Feature
+		if err := client.GetFeature(ctx, knownPoint, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.GetFeature(context.Background(), knownPoint, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(headers) != 2 || headers[0] == "" || headers[1] != "" {
+			t.Errorf("expected a timeout header on the first call only; got '%q'", headers)
+		}
+	})
+
+	t.Run("DeadlineWhileReadingBody", func(t *testing.T) {
+		// The server sends the headers and part of the body, then stalls until the client gives up
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", duh.ContentTypeProtoBuf)
+			w.Header().Set("Content-Length", "1024")
+			w.WriteHeader(duh.CodeOK)
+			_, _ = w.Write([]byte{0x0a})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer hs.Close()
+
+		for name, client := range map[string]*benchmark.HTTPClient{
+			"Generated": benchmark.NewClient(hs.Client(), hs.URL),
+			"Pooled":    benchmark.NewClient(hs.Client(), hs.URL, benchmark.WithPooling()),
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+				defer cancel()
+				var resp pb.Feature
+				var de duh.Error
+				err := client.GetFeature(ctx, knownPoint, &resp)
+				if !errors.As(err, &de) || de.Code() != benchmark.CodeDeadlineExceeded {
+					t.Fatalf("expected error with code '%d'; got '%v'", benchmark.CodeDeadlineExceeded, err)
+				}
+			})
+		}
+	})
+
+	t.Run("InfraError", func(t *testing.T) {
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}))
+		defer hs.Close()
+		client := benchmark.NewClient(hs.Client(), hs.URL, benchmark.WithPooling())
+
+		var resp pb.Feature
+		var de duh.Error
+		if err := client.GetFeature(ctx, knownPoint, &resp); !errors.As(err, &de) || de.Code() != http.StatusBadGateway {
+			t.Fatalf("expected error with code '%d'; got '%v'", http.StatusBadGateway, err)
+		}
+	})
+}
+
+// BenchmarkHTTPClient compares the allocations of the generated client with a client created
+// WithPooling(), over HTTP/1 and H2C. GeneratedProto separates the cost of the JSON replies the
+// generated client receives from the cost of the allocations the pooled client avoids.
+func BenchmarkHTTPClient(b *testing.B) {
+	for _, transport := range []struct {
+		name   string
+		server func(http.Handler) *httptest.Server
+		client func() *http.Client
+	}{
+		{
+			name:   "HTTP1",
+			server: httptest.NewServer,
+			client: func() *http.Client {
+				return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+			},
+		},
+		{
+			name: "HTTP2",
+			server: func(h http.Handler) *httptest.Server {
+				return httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+			},
+			client: func() *http.Client {
+				return &http.Client{Transport: &http2.Transport{
+					AllowHTTP: true,
+					DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, network, addr)
+					},
+				}}
+			},
+		},
+	} {
+		srv := transport.server(benchmark.NewHTTPHandler(server.NewRouteGuideServer()))
+		type client interface {
+			GetFeature(context.Context, *pb.Point, *pb.Feature) error
+		}
+		for _, c := range []struct {
+			name string
+			new  func(hc *http.Client, endpoint string) client
+		}{
+			{name: "Generated", new: func(hc *http.Client, endpoint string) client {
+				return benchmark.NewClient(hc, endpoint)
+			}},
+			// The generated client asking for protobuf replies, as the pooled client does
+			{name: "GeneratedProto", new: func(hc *http.Client, endpoint string) client {
+				return pb.NewRouteGuideDUHClient(pb.RouteGuideDUHClientConfig{
+					Endpoint: endpoint,
+					Client:   hc,
+					Prepare: func(_ context.Context, r *http.Request) error {
+						r.Header.Set("Accept", duh.ContentTypeProtoBuf)
+						return nil
+					},
+				})
+			}},
+			{name: "Pooled", new: func(hc *http.Client, endpoint string) client {
+				return benchmark.NewClient(hc, endpoint, benchmark.WithPooling())
+			}},
+		} {
+			b.Run(transport.name+"/"+c.name, func(b *testing.B) {
+				hc := transport.client()
+				defer hc.CloseIdleConnections()
+				client := c.new(hc, srv.URL)
+				ctx := context.Background()
+
+				var resp pb.Feature
+				if err := client.GetFeature(ctx, knownPoint, &resp); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					if err := client.GetFeature(ctx, knownPoint, &resp); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		srv.Close()
+	}
+}
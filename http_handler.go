@@ -1,45 +1,122 @@
 package benchmark
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/duh-rpc/duh-go"
 	"github.com/duh-rpc/duh-go-benchmarks/server"
 	v1 "github.com/duh-rpc/duh-go-benchmarks/v1"
 )
 
+// HeaderTimeout is the request header used to propagate the client's context deadline to the
+// server, similar to the `grpc-timeout` header used by gRPC. The value is the time remaining
+// until the deadline in the format accepted by time.ParseDuration()
+const HeaderTimeout = "X-Duh-Timeout"
+
+// CodeDeadlineExceeded is the code of calls whose deadline expired before the server replied,
+// either on the server which enforced the HeaderTimeout or on the client. gRPC reports the same
+// condition as codes.DeadlineExceeded. duh-go defines no such code, so the gateway timeout status
+// is used.
+const CodeDeadlineExceeded = http.StatusGatewayTimeout
+
 func NewHTTPHandler(service *server.RouteGuideService) *Handler {
-	return &Handler{service: service}
+	return &Handler{router: v1.NewRouteGuideDUHHandler(duhService{service: service})}
 }
 
 type Handler struct {
-	service *server.RouteGuideService
+	router *v1.RouteGuideDUHHandler
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.URL.Path {
-	// Use a simple switch for static routes just like GRPC does
-	case "/v1/route.getFeature":
-		h.handleGetFeature(w, r)
-		return
-	case "/v1/say.hello":
+	// If the client provided a timeout, the server should stop working on the request
+	// once the client is no longer waiting for the reply.
+	if v := r.Header.Get(HeaderTimeout); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			duh.ReplyWithCode(w, r, duh.CodeBadRequest, nil, "invalid '"+HeaderTimeout+"' header; "+err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	// Used by the benchmarks to establish a connection before the benchmark begins
+	if r.URL.Path == "/v1/say.hello" {
 		w.Header().Set("Content-Type", duh.ContentOctetStream)
 		_, _ = w.Write([]byte("Hello!"))
 		return
 	}
-	duh.ReplyWithCode(w, r, duh.CodeNotImplemented, nil, "no such method; "+r.URL.Path)
+	h.router.ServeHTTP(w, r)
 }
 
-func (h *Handler) handleGetFeature(w http.ResponseWriter, r *http.Request) {
-	var req v1.Point
-	if err := duh.ReadRequest(r, &req); err != nil {
-		duh.ReplyError(w, r, err)
+// NewCodecHandler serves the RouteGuide service as Handler does, but marshals and un-marshals the
+// protobuf payloads with the codec instead of duh-go and the generated router. Comparing the
+// handlers created with ProtoCodec and VTProtoCodec measures the cost of serialization alone.
+func NewCodecHandler(service *server.RouteGuideService, codec Codec) http.Handler {
+	return &codecHandler{wire: newWireHandler(service, codec)}
+}
+
+type codecHandler struct {
+	wire wireHandler
+}
+
+func (h *codecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	in, out := bufferPool.Get().(*bytes.Buffer), bufferPool.Get().(*bytes.Buffer)
+	in.Reset()
+	out.Reset()
+	defer func() {
+		bufferPool.Put(in)
+		bufferPool.Put(out)
+	}()
+
+	if _, err := in.ReadFrom(r.Body); err != nil {
+		duh.ReplyWithCode(w, r, duh.CodeBadRequest, nil, "while reading request body: "+err.Error())
 		return
 	}
-	resp, err := h.service.GetFeature(r.Context(), &req)
+	reply := h.wire.serve(r.Context(), wireRequest{
+		method:      []byte(r.Method),
+		path:        []byte(r.URL.Path),
+		contentType: []byte(r.Header.Get("Content-Type")),
+		accept:      []byte(r.Header.Get("Accept")),
+		timeout:     []byte(r.Header.Get(HeaderTimeout)),
+		body:        in.Bytes(),
+	}, out.AvailableBuffer())
+
+	w.Header().Set("Content-Type", reply.contentType)
+	w.WriteHeader(reply.code)
+	_, _ = w.Write(reply.body)
+	// Keep the buffer if the reply grew it, so the next reply can use the larger buffer
+	if cap(reply.body) > out.Cap() {
+		out = bytes.NewBuffer(reply.body[:0])
+	}
+}
+
+// duhService adapts RouteGuideService to the generated v1.RouteGuideDUHServer interface
+type duhService struct {
+	service *server.RouteGuideService
+}
+
+func (s duhService) GetFeature(ctx context.Context, req *v1.Point) (*v1.Feature, error) {
+	resp, err := s.service.FindFeature(ctx, req)
 	if err != nil {
-		duh.ReplyError(w, r, err)
-		return
+		return nil, toServiceError(err)
+	}
+	return resp, nil
+}
+
+// toServiceError converts errors returned by RouteGuideService.FindFeature into DUH service errors
+func toServiceError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return duh.NewServiceError(CodeDeadlineExceeded, err, nil)
+	}
+	var nf *server.NotFoundError
+	if errors.As(err, &nf) {
+		return duh.NewServiceError(duh.CodeNotFound, nf, nf.Details)
 	}
-	duh.Reply(w, r, duh.CodeOK, resp)
+	return err
 }
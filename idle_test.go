@@ -0,0 +1,214 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	idleConns = flag.String("idle-conns", "", "comma separated number of idle connections opened to each "+
+		"transport by BenchmarkIdleConns, for example '1000,10000' (default is to skip the benchmark)")
+	idleTime = flag.Duration("idle-time", 30*time.Second, "how long BenchmarkIdleConns holds the connections "+
+		"open while measuring the cost of keepalive pings")
+	idleKeepalive = flag.Duration("idle-keepalive", 10*time.Second, "interval of gRPC and HTTP/2 keepalive "+
+		"pings during BenchmarkIdleConns; gRPC clients will not ping more often than every 10s")
+)
+
+// idleDialers open a single idle connection for each transport. Each connection has its own client,
+// as a gateway's connections would each come from a different client.
+var idleDialers = map[string]func(ctx context.Context, addr string, conf *benchmark.TLSConfig,
+	conns *benchmark.ConnStats) (func(), error){
+	"GRPC":  dialIdleGRPC,
+	"HTTP2": dialIdleH2C,
+	"HTTP1": dialIdleHTTP1,
+	"HTTPS": dialIdleHTTPS,
+}
+
+// BenchmarkIdleConns measures the goroutines, heap and RSS each idle connection holds on the server and
+// the client, and the CPU and bytes spent on keepalive pings while the connections are idle. The server
+// always runs in its own process so the client and server can be measured separately. The connections
+// are opened once regardless of b.N.
+func BenchmarkIdleConns(b *testing.B) {
+	if *idleConns == "" {
+		b.Skip("pass -idle-conns to open idle connections to each transport")
+	}
+	counts, err := parseInts("idle-conns", *idleConns)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var conf benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTP1", "HTTPS"} {
+		for _, n := range counts {
+			b.Run(fmt.Sprintf("%s/%d", transport, n), func(b *testing.B) {
+				runIdleConns(b, transport, n, &conf)
+			})
+		}
+	}
+}
+
+func runIdleConns(b *testing.B, transport string, n int, conf *benchmark.TLSConfig) {
+	ctx := context.Background()
+	var tlsConf *benchmark.TLSConfig
+	if transport == "HTTPS" {
+		tlsConf = conf
+	}
+	side, err := spawnServer(b, transport, "localhost:0", tlsConf, "-keepalive", idleKeepalive.String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer side.Close()
+
+	serverBefore, err := side.Footprint(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	clientBefore := benchmark.ReadFootprint()
+
+	// Open the connections concurrently, as connecting one at a time takes too long with 10k connections
+	conns := &benchmark.ConnStats{}
+	closers := make([]func(), n)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	next := make(chan int)
+	for w := 0; w < 64; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				closer, err := idleDialers[transport](ctx, side.addr, conf, conns)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("while opening connection %d: %w", i, err)
+				}
+				closers[i] = closer
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	defer func() {
+		for _, closer := range closers {
+			if closer != nil {
+				closer()
+			}
+		}
+	}()
+	if firstErr != nil {
+		b.Fatal(firstErr)
+	}
+
+	serverAfter, err := side.Footprint(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	clientAfter := benchmark.ReadFootprint()
+
+	// Measure what it costs to keep the connections alive while they are idle
+	statsBefore, err := side.Stats(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	usageBefore, connBefore := benchmark.ReadUsage(), conns.Counts()
+	time.Sleep(*idleTime)
+	usage, conn := benchmark.ReadUsage().Sub(usageBefore), conns.Counts().Sub(connBefore)
+	statsAfter, err := side.Stats(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	serverUsage := statsAfter.Usage.Sub(statsBefore.Usage)
+
+	perConn := func(after, before float64) float64 { return (after - before) / float64(n) }
+	perConnSecond := func(v float64) float64 { return v / float64(n) / idleTime.Seconds() }
+
+	// ns/op is meaningless as b.N is ignored, reporting zero removes it from the output
+	b.ReportMetric(0, "ns/op")
+	b.ReportMetric(perConn(float64(serverAfter.Goroutines), float64(serverBefore.Goroutines)), "server-goroutines/conn")
+	b.ReportMetric(perConn(float64(serverAfter.HeapInuse), float64(serverBefore.HeapInuse)), "server-heap-B/conn")
+	b.ReportMetric(perConn(float64(serverAfter.RSS), float64(serverBefore.RSS)), "server-rss-B/conn")
+	b.ReportMetric(perConn(float64(clientAfter.Goroutines), float64(clientBefore.Goroutines)), "client-goroutines/conn")
+	b.ReportMetric(perConn(float64(clientAfter.HeapInuse), float64(clientBefore.HeapInuse)), "client-heap-B/conn")
+	b.ReportMetric(perConn(float64(clientAfter.RSS), float64(clientBefore.RSS)), "client-rss-B/conn")
+	b.ReportMetric(perConnSecond(float64(serverUsage.CPUTime().Nanoseconds())), "server-cpu-ns/conn/s")
+	b.ReportMetric(perConnSecond(float64(usage.CPUTime().Nanoseconds())), "client-cpu-ns/conn/s")
+	b.ReportMetric(perConnSecond(float64(conn.BytesRead+conn.BytesWritten)), "idle-B/conn/s")
+}
+
+func dialIdleGRPC(ctx context.Context, addr string, _ *benchmark.TLSConfig,
+	conns *benchmark.ConnStats) (func(), error) {
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: *idleKeepalive, PermitWithoutStream: true}),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return conns.DialContext(ctx, "tcp", addr)
+		}))
+	if err != nil {
+		return nil, err
+	}
+	closer := func() { _ = conn.Close() }
+	if _, err := pb.NewRouteGuideClient(conn).GetFeature(ctx, knownPoint); err != nil {
+		return closer, err
+	}
+	return closer, nil
+}
+
+func dialIdleH2C(ctx context.Context, addr string, _ *benchmark.TLSConfig,
+	conns *benchmark.ConnStats) (func(), error) {
+	return dialIdleHTTP(ctx, "http://"+addr, &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return conns.DialContext(ctx, network, addr)
+		},
+		ReadIdleTimeout: *idleKeepalive,
+	})
+}
+
+func dialIdleHTTP1(ctx context.Context, addr string, _ *benchmark.TLSConfig,
+	conns *benchmark.ConnStats) (func(), error) {
+	// HTTP/1 has no keepalive pings, idle connections are only kept alive by TCP keepalive
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = conns.DialContext
+	return dialIdleHTTP(ctx, "http://"+addr, transport)
+}
+
+func dialIdleHTTPS(ctx context.Context, addr string, conf *benchmark.TLSConfig,
+	conns *benchmark.ConnStats) (func(), error) {
+	return dialIdleHTTP(ctx, "https://"+addr, &http2.Transport{
+		TLSClientConfig: conf.ClientTLS,
+		DialTLSContext:  conns.DialTLSContext,
+		ReadIdleTimeout: *idleKeepalive,
+	})
+}
+
+// dialIdleHTTP makes a single call, which leaves an idle connection in the transport's pool
+func dialIdleHTTP(ctx context.Context, endpoint string, transport interface {
+	http.RoundTripper
+	CloseIdleConnections()
+}) (func(), error) {
+	client := benchmark.NewClient(&http.Client{Transport: transport}, endpoint)
+	var resp pb.Feature
+	return transport.CloseIdleConnections, client.GetFeature(ctx, knownPoint, &resp)
+}
@@ -0,0 +1,155 @@
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+const (
+	ProfileCPU   = "cpu"
+	ProfileHeap  = "heap"
+	ProfileMutex = "mutex"
+	ProfileBlock = "block"
+
+	// iterationsComment is the prefix of the comment added to each profile which records the
+	// number of iterations the profile covers, so profiles can be compared per operation.
+	iterationsComment = "iterations="
+)
+
+// ignoreProfiler matches the functions which capture and write profiles, so the cost of profiling
+// does not appear in the profiles of the run
+var ignoreProfiler = regexp.MustCompile(`^runtime/pprof\.|duh-go-benchmarks\.(StartProfile|lookupProfile|\(\*Profile\))`)
+
+// runtimeProfiles maps the profiles captured as a delta between the start and end of a run to
+// the name of the runtime profile they are derived from.
+var runtimeProfiles = map[string]string{
+	ProfileHeap:  "allocs",
+	ProfileMutex: "mutex",
+	ProfileBlock: "block",
+}
+
+// EnableProfiling turns on mutex and block profiling, which the runtime disables by default.
+// Both have a noticeable cost, so results recorded while profiling should not be compared
+// with results recorded without.
+func EnableProfiling() {
+	runtime.SetMutexProfileFraction(5)
+	runtime.SetBlockProfileRate(1000)
+}
+
+// Profile captures CPU, heap, mutex and block profiles for a single benchmark run
+type Profile struct {
+	dir  string
+	cpu  bytes.Buffer
+	base map[string]*profile.Profile
+}
+
+// StartProfile begins capturing profiles which will be written to dir when Stop() is called
+func StartProfile(dir string) (*Profile, error) {
+	p := &Profile{dir: dir, base: make(map[string]*profile.Profile)}
+
+	// The heap, mutex and block profiles are cumulative for the life of the process, so
+	// take a snapshot which is subtracted from the profile when the run is complete.
+	runtime.GC()
+	for name, rt := range runtimeProfiles {
+		base, err := lookupProfile(rt)
+		if err != nil {
+			return nil, err
+		}
+		p.base[name] = base
+	}
+
+	if err := pprof.StartCPUProfile(&p.cpu); err != nil {
+		return nil, fmt.Errorf("while starting CPU profile: %w", err)
+	}
+	return p, nil
+}
+
+// Stop stops the CPU profile and writes all profiles to the directory provided to StartProfile().
+// Iterations is the number of operations the run performed and is recorded in each profile.
+func (p *Profile) Stop(iterations int) error {
+	pprof.StopCPUProfile()
+
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return fmt.Errorf("while creating profile directory: %w", err)
+	}
+
+	cpu, err := profile.Parse(&p.cpu)
+	if err != nil {
+		return fmt.Errorf("while parsing CPU profile: %w", err)
+	}
+	if err := p.write(ProfileCPU, cpu, iterations); err != nil {
+		return err
+	}
+
+	runtime.GC()
+	for name, rt := range runtimeProfiles {
+		end, err := lookupProfile(rt)
+		if err != nil {
+			return err
+		}
+		base := p.base[name]
+		base.Scale(-1)
+		delta, err := profile.Merge([]*profile.Profile{end, base})
+		if err != nil {
+			return fmt.Errorf("while subtracting base %s profile: %w", name, err)
+		}
+		if err := p.write(name, delta, iterations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Profile) write(name string, prof *profile.Profile, iterations int) error {
+	prof.FilterSamplesByName(nil, ignoreProfiler, nil, nil)
+	prof.Comments = append(prof.Comments, iterationsComment+strconv.Itoa(iterations))
+
+	f, err := os.Create(filepath.Join(p.dir, name+".pprof"))
+	if err != nil {
+		return fmt.Errorf("while creating %s profile: %w", name, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := prof.Write(f); err != nil {
+		return fmt.Errorf("while writing %s profile: %w", name, err)
+	}
+	return f.Close()
+}
+
+func lookupProfile(name string) (*profile.Profile, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+		return nil, fmt.Errorf("while writing %s profile: %w", name, err)
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s profile: %w", name, err)
+	}
+	return p, nil
+}
+
+// ProfileIterations returns the number of iterations recorded in a profile written by Profile.Stop(),
+// or zero if the profile has no iteration count.
+func ProfileIterations(p *profile.Profile) int {
+	for _, c := range p.Comments {
+		if v, ok := strings.CutPrefix(c, iterationsComment); ok {
+			n, _ := strconv.Atoi(v)
+			return n
+		}
+	}
+	return 0
+}
+
+// ProfileDir returns the directory profiles for the transport and scenario are written to
+func ProfileDir(root, transport, scenario string) string {
+	return filepath.Join(root, transport, scenario)
+}
@@ -0,0 +1,396 @@
+package benchmark
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Result is the structured record of a single transport and scenario benchmark run
+type Result struct {
+	// Transport is the name of the transport benchmarked, for example "GRPC" or "HTTP1"
+	Transport string `json:"transport"`
+	// Scenario is the name of the workload, for example "GetFeature()"
+	Scenario string `json:"scenario"`
+	// Sample is the index of the run when the benchmark is run multiple times via `-count`
+	Sample int `json:"sample"`
+	// Protocol is the protocol negotiated between client and server, for example "HTTP/2.0"
+	Protocol    string      `json:"protocol"`
+	Iterations  int         `json:"iterations"`
+	NsPerOp     float64     `json:"ns_per_op"`
+	AllocsPerOp float64     `json:"allocs_per_op"`
+	BytesPerOp  float64     `json:"bytes_per_op"`
+	Latency     Percentiles `json:"latency"`
+	// Metrics are any additional metrics reported by the scenario, keyed by unit
+	Metrics     map[string]float64 `json:"metrics,omitempty"`
+	Environment Environment        `json:"environment"`
+}
+
+// Percentiles of the per operation latency in nanoseconds
+type Percentiles struct {
+	P50 int64 `json:"p50_ns"`
+	P90 int64 `json:"p90_ns"`
+	P99 int64 `json:"p99_ns"`
+	Max int64 `json:"max_ns"`
+}
+
+// Environment describes the machine and build which produced a Result
+type Environment struct {
+	Timestamp   time.Time `json:"timestamp"`
+	GoVersion   string    `json:"go_version"`
+	GOOS        string    `json:"goos"`
+	GOARCH      string    `json:"goarch"`
+	CPU         string    `json:"cpu"`
+	GOMAXPROCS  int       `json:"gomaxprocs"`
+	GitSHA      string    `json:"git_sha"`
+	GRPCVersion string    `json:"grpc_version"`
+	DuhVersion  string    `json:"duh_version"`
+	// NetVersion and ProtobufVersion are the versions of golang.org/x/net, which provides the
+	// HTTP/2 client and server, and of google.golang.org/protobuf
+	NetVersion      string `json:"net_version,omitempty"`
+	ProtobufVersion string `json:"protobuf_version,omitempty"`
+}
+
+// NewPercentiles calculates latency percentiles from the provided durations. The
+// provided slice is sorted in place.
+func NewPercentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	at := func(p float64) int64 {
+		i := int(float64(len(durations))*p+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(durations) {
+			i = len(durations) - 1
+		}
+		return int64(durations[i])
+	}
+	return Percentiles{
+		P50: at(0.50),
+		P90: at(0.90),
+		P99: at(0.99),
+		Max: int64(durations[len(durations)-1]),
+	}
+}
+
+// DetectEnvironment gathers metadata about the machine and build running the benchmarks
+func DetectEnvironment() Environment {
+	env := Environment{
+		Timestamp:  time.Now().UTC(),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		CPU:        cpuModel(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		GitSHA:     gitSHA(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			switch dep.Path {
+			case "google.golang.org/grpc":
+				env.GRPCVersion = dep.Version
+			case "github.com/duh-rpc/duh-go":
+				env.DuhVersion = dep.Version
+			case "golang.org/x/net":
+				env.NetVersion = dep.Version
+			case "google.golang.org/protobuf":
+				env.ProtobufVersion = dep.Version
+			}
+		}
+	}
+	return env
+}
+
+// cpuModel returns the model name of the CPU, or "unknown" if it could not be determined
+func cpuModel() string {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/cpuinfo")
+		if err != nil {
+			return "unknown"
+		}
+		defer func() { _ = f.Close() }()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if ok && strings.TrimSpace(key) == "model name" {
+				return strings.TrimSpace(value)
+			}
+		}
+	case "darwin":
+		out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return "unknown"
+}
+
+// gitSHA returns the commit of the working tree, with a "-dirty" suffix if there are
+// uncommitted changes, or "unknown" if git is not available.
+func gitSHA() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	sha := strings.TrimSpace(string(out))
+
+	out, err = exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		sha += "-dirty"
+	}
+	return sha
+}
+
+// Recorder collects Results from benchmark runs. Since testing.B calls a benchmark
+// multiple times with increasing b.N, only the last Result for each transport,
+// scenario and sample is kept.
+type Recorder struct {
+	mu      sync.Mutex
+	env     Environment
+	results []Result
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{env: DetectEnvironment()}
+}
+
+// Add records the result, replacing any previous result for the same transport, scenario and sample
+func (r *Recorder) Add(res Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res.Environment = r.env
+	// GOMAXPROCS changes between runs when the `-cpu` flag is used
+	res.Environment.GOMAXPROCS = runtime.GOMAXPROCS(0)
+	for i := range r.results {
+		if r.results[i].Transport == res.Transport && r.results[i].Scenario == res.Scenario &&
+			r.results[i].Sample == res.Sample {
+			r.results[i] = res
+			return
+		}
+	}
+	r.results = append(r.results, res)
+}
+
+// Results returns a copy of the results recorded
+func (r *Recorder) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Result(nil), r.results...)
+}
+
+// WriteFile writes the recorded results to the file in the format requested. If the
+// format is empty, the format is chosen by the file extension.
+func (r *Recorder) WriteFile(path, format string) error {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("while creating results file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	switch format {
+	case FormatJSON, "jsonl":
+		err = WriteJSON(f, r.Results())
+	case FormatCSV:
+		err = WriteCSV(f, r.Results())
+	default:
+		return fmt.Errorf("unknown results format '%s'; expected one of [%s, %s]", format, FormatJSON, FormatCSV)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJSON writes one JSON record per line for each result
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	for _, res := range results {
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("while encoding result: %w", err)
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"transport", "scenario", "sample", "protocol", "iterations", "ns_per_op", "allocs_per_op", "bytes_per_op",
+	"p50_ns", "p90_ns", "p99_ns", "max_ns", "metrics", "timestamp", "go_version", "goos", "goarch",
+	"cpu", "gomaxprocs", "git_sha", "grpc_version", "duh_version", "net_version", "protobuf_version",
+}
+
+// optionalColumns were added to csvHeader after results were first recorded, so files without
+// them can still be read
+var optionalColumns = map[string]bool{"net_version": true, "protobuf_version": true}
+
+// WriteCSV writes a header followed by one CSV record for each result. Additional
+// metrics are encoded as 'unit=value' pairs separated by ';'
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		var metrics []string
+		for unit, v := range res.Metrics {
+			metrics = append(metrics, unit+"="+formatFloat(v))
+		}
+		sort.Strings(metrics)
+
+		env := res.Environment
+		if err := cw.Write([]string{
+			res.Transport, res.Scenario, strconv.Itoa(res.Sample), res.Protocol, strconv.Itoa(res.Iterations),
+			formatFloat(res.NsPerOp), formatFloat(res.AllocsPerOp), formatFloat(res.BytesPerOp),
+			strconv.FormatInt(res.Latency.P50, 10), strconv.FormatInt(res.Latency.P90, 10),
+			strconv.FormatInt(res.Latency.P99, 10), strconv.FormatInt(res.Latency.Max, 10),
+			strings.Join(metrics, ";"), env.Timestamp.Format(time.RFC3339), env.GoVersion, env.GOOS,
+			env.GOARCH, env.CPU, strconv.Itoa(env.GOMAXPROCS), env.GitSHA, env.GRPCVersion, env.DuhVersion,
+			env.NetVersion, env.ProtobufVersion,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadResults reads results from a file written by WriteJSON or WriteCSV
+func ReadResults(path string) ([]Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if filepath.Ext(path) == "."+FormatCSV {
+		return readCSV(f)
+	}
+	return readJSON(f)
+}
+
+func readJSON(r io.Reader) ([]Result, error) {
+	var results []Result
+	dec := json.NewDecoder(r)
+	for {
+		var res Result
+		if err := dec.Decode(&res); err != nil {
+			if errors.Is(err, io.EOF) {
+				return results, nil
+			}
+			return nil, fmt.Errorf("while decoding result: %w", err)
+		}
+		results = append(results, res)
+	}
+}
+
+func readCSV(r io.Reader) ([]Result, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("while reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	for _, name := range csvHeader {
+		if _, ok := col[name]; !ok && !optionalColumns[name] {
+			return nil, fmt.Errorf("CSV header missing column '%s'", name)
+		}
+	}
+
+	var results []Result
+	for _, rec := range records[1:] {
+		get := func(name string) string {
+			i, ok := col[name]
+			if !ok {
+				return ""
+			}
+			return rec[i]
+		}
+		res := Result{
+			Transport:   get("transport"),
+			Scenario:    get("scenario"),
+			Sample:      atoi(get("sample")),
+			Protocol:    get("protocol"),
+			Iterations:  atoi(get("iterations")),
+			NsPerOp:     atof(get("ns_per_op")),
+			AllocsPerOp: atof(get("allocs_per_op")),
+			BytesPerOp:  atof(get("bytes_per_op")),
+			Latency: Percentiles{
+				P50: int64(atoi(get("p50_ns"))),
+				P90: int64(atoi(get("p90_ns"))),
+				P99: int64(atoi(get("p99_ns"))),
+				Max: int64(atoi(get("max_ns"))),
+			},
+			Environment: Environment{
+				GoVersion:       get("go_version"),
+				GOOS:            get("goos"),
+				GOARCH:          get("goarch"),
+				CPU:             get("cpu"),
+				GOMAXPROCS:      atoi(get("gomaxprocs")),
+				GitSHA:          get("git_sha"),
+				GRPCVersion:     get("grpc_version"),
+				DuhVersion:      get("duh_version"),
+				NetVersion:      get("net_version"),
+				ProtobufVersion: get("protobuf_version"),
+			},
+		}
+		res.Environment.Timestamp, _ = time.Parse(time.RFC3339, get("timestamp"))
+		for _, pair := range strings.Split(get("metrics"), ";") {
+			if unit, v, ok := strings.Cut(pair, "="); ok {
+				if res.Metrics == nil {
+					res.Metrics = make(map[string]float64)
+				}
+				res.Metrics[unit] = atof(v)
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+func atof(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,58 @@
+package benchmark_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+)
+
+func TestResultsRoundTrip(t *testing.T) {
+	rec := benchmark.NewRecorder()
+	rec.Add(benchmark.Result{
+		Transport:   "HTTP1",
+		Scenario:    "GetFeature()",
+		Protocol:    "HTTP/1.1",
+		Iterations:  1000,
+		NsPerOp:     49005.5,
+		AllocsPerOp: 120,
+		BytesPerOp:  8123.25,
+		Latency: benchmark.NewPercentiles([]time.Duration{
+			4 * time.Microsecond, time.Microsecond, 3 * time.Microsecond, 2 * time.Microsecond,
+		}),
+		Metrics: map[string]float64{"canceled/op": 1},
+	})
+	// A second run of the same scenario replaces the first
+	rec.Add(benchmark.Result{Transport: "GRPC", Scenario: "GetFeature()", Iterations: 1})
+	rec.Add(benchmark.Result{Transport: "GRPC", Scenario: "GetFeature()", Iterations: 2})
+
+	expected := rec.Results()
+	if len(expected) != 2 {
+		t.Fatalf("expected 2 results; got '%d'", len(expected))
+	}
+	if expected[0].Latency.P50 != int64(2*time.Microsecond) || expected[0].Latency.Max != int64(4*time.Microsecond) {
+		t.Fatalf("unexpected percentiles '%+v'", expected[0].Latency)
+	}
+
+	for _, ext := range []string{"json", "csv"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results."+ext)
+			if err := rec.WriteFile(path, ""); err != nil {
+				t.Fatal(err)
+			}
+			got, err := benchmark.ReadResults(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				// CSV timestamps are truncated to the second
+				got[i].Environment.Timestamp = expected[i].Environment.Timestamp
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected '%+v'; got '%+v'", expected, got)
+			}
+		})
+	}
+}
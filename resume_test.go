@@ -0,0 +1,306 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	resume = flag.Bool("resume", false, "run BenchmarkResume, which leaves the connection of each transport "+
+		"idle for -resume-idle before every call")
+	resumeIdle = flag.Duration("resume-idle", 2*time.Second, "how long BenchmarkResume leaves the connection "+
+		"idle before each call")
+	resumeTimeout = flag.Duration("resume-timeout", time.Second, "idle timeout of the servers in the "+
+		"IdleTimeout scenario of BenchmarkResume")
+	resumePing = flag.Duration("resume-ping", 500*time.Millisecond, "interval of client keepalive pings in the "+
+		"Ping scenario of BenchmarkResume; gRPC clients will not ping more often than every 10s")
+)
+
+// grpcMinPing is the shortest interval of keepalive pings a gRPC client sends, grpc-go raises
+// keepalive.ClientParameters.Time to at least this
+const grpcMinPing = 10 * time.Second
+
+// resumeMode is how a connection is kept, or not kept, alive while it is idle
+type resumeMode struct {
+	name string
+	// timeout is the idle timeout of the server, zero never closes idle connections
+	timeout time.Duration
+	// ping is the interval of client keepalive pings, zero never pings
+	ping time.Duration
+}
+
+// resumeTransports serve the service with the idle timeout of the mode, and return a client which
+// pings as the mode requires. Every connection the client opens is counted by dials.
+var resumeTransports = map[string]func(tb testing.TB, mode resumeMode, conf *benchmark.TLSConfig,
+	dials *dialCounter) func(context.Context) error{
+	"GRPC":  resumeGRPC,
+	"HTTP2": resumeH2C,
+	"HTTP1": resumeHTTP1,
+	"HTTPS": resumeHTTPS,
+}
+
+func TestResume(t *testing.T) {
+	const idle = 200 * time.Millisecond
+	var conf benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTP1", "HTTPS"} {
+		for _, mode := range []resumeMode{{name: "Warm"}, {name: "IdleTimeout", timeout: 50 * time.Millisecond}} {
+			t.Run(transport+"/"+mode.name, func(t *testing.T) {
+				dials := &dialCounter{}
+				getFeature := resumeTransports[transport](t, mode, &conf, dials)
+				ctx := context.Background()
+				if err := getFeature(ctx); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(idle)
+				if err := getFeature(ctx); err != nil {
+					t.Fatalf("expected the call after %s idle to succeed; got '%v'", idle, err)
+				}
+
+				// The server must have closed the idle connection, so the client dials a new one
+				if mode.timeout != 0 && dials.Load() < 2 {
+					t.Errorf("expected a new connection after the idle timeout; got '%d' dials", dials.Load())
+				}
+				if mode.timeout == 0 && dials.Load() != 1 {
+					t.Errorf("expected the connection to be reused; got '%d' dials", dials.Load())
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkResume leaves the connection of each transport idle before every call, as bursty traffic
+// does, and reports the latency of the call which resumes the connection, how often it fails and how
+// often the client had to dial a new connection. The scenarios are
+//
+//   - Warm: the server never closes idle connections and the client never pings.
+//   - IdleTimeout: the server closes connections idle for longer than -resume-timeout, with
+//     http.Server.IdleTimeout or the gRPC MaxConnectionIdle.
+//   - Ping: the client pings the idle connection every -resume-ping, with the ReadIdleTimeout of
+//     the http2.Transport or gRPC keepalive. gRPC clients ping at most every 10s, so the gRPC
+//     server permits pings without active streams at that rate.
+//
+// Each call waits for -resume-idle, so b.N is limited by the -benchtime. The client only pings a
+// connection which is idle for longer than the ping interval, so with pings each call waits for
+// the ping interval plus -resume-idle.
+func BenchmarkResume(b *testing.B) {
+	if !*resume {
+		b.Skip("pass -resume to benchmark calls on connections which have been idle")
+	}
+	var conf benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		b.Fatal(err)
+	}
+
+	modes := []resumeMode{
+		{name: "Warm"},
+		{name: "IdleTimeout", timeout: *resumeTimeout},
+		{name: "Ping", ping: *resumePing},
+	}
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTP1", "HTTPS"} {
+		for _, mode := range modes {
+			// HTTP/1 has no keepalive pings
+			if transport == "HTTP1" && mode.ping != 0 {
+				continue
+			}
+			idle := *resumeIdle
+			if mode.ping != 0 {
+				idle += resumePingInterval(transport, mode)
+			}
+			b.Run(transport+"/"+mode.name, func(b *testing.B) {
+				dials := &dialCounter{}
+				getFeature := resumeTransports[transport](b, mode, &conf, dials)
+				ctx := context.Background()
+				if err := getFeature(ctx); err != nil {
+					b.Fatal(err)
+				}
+
+				var errs int
+				var latency time.Duration
+				before := dials.Load()
+				for n := 0; n < b.N; n++ {
+					time.Sleep(idle)
+					start := time.Now()
+					err := getFeature(ctx)
+					latency += time.Since(start)
+					if err != nil {
+						if errs == 0 {
+							b.Logf("first error after %s idle: %s", idle, err)
+						}
+						errs++
+					}
+				}
+
+				// ns/op is dominated by the idle time, the latency of the resumed call is reported instead
+				b.ReportMetric(0, "ns/op")
+				b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N), "resume-ns/op")
+				b.ReportMetric(float64(errs)/float64(b.N), "errors/op")
+				b.ReportMetric(float64(dials.Load()-before)/float64(b.N), "dials/op")
+			})
+		}
+	}
+}
+
+// resumePingInterval returns how often the client of the transport pings in the mode
+func resumePingInterval(transport string, mode resumeMode) time.Duration {
+	if transport == "GRPC" && mode.ping != 0 {
+		return max(mode.ping, grpcMinPing)
+	}
+	return mode.ping
+}
+
+// dialCounter dials connections and counts them
+type dialCounter struct {
+	atomic.Int64
+}
+
+func (d *dialCounter) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.Add(1)
+	var nd net.Dialer
+	return nd.DialContext(ctx, network, addr)
+}
+
+func (d *dialCounter) DialTLSContext(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	c, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := tls.Client(c, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+func resumeGRPC(tb testing.TB, mode resumeMode, _ *benchmark.TLSConfig, dials *dialCounter) func(context.Context) error {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var opts []grpc.ServerOption
+	if mode.timeout != 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: mode.timeout}))
+	}
+	ping := resumePingInterval("GRPC", mode)
+	if ping != 0 {
+		// The default policy closes connections which ping more often than every 5m or without
+		// active streams
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             ping,
+			PermitWithoutStream: true,
+		}))
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterRouteGuideServer(srv, server.NewRouteGuideServer())
+	go func() { _ = srv.Serve(l) }()
+	tb.Cleanup(srv.Stop)
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dials.DialContext(ctx, "tcp", addr)
+		}),
+	}
+	if ping != 0 {
+		dialOpts = append(dialOpts,
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: ping, PermitWithoutStream: true}))
+	}
+	conn, err := grpc.Dial(l.Addr().String(), dialOpts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = conn.Close() })
+	client := pb.NewRouteGuideClient(conn)
+
+	return func(ctx context.Context) error {
+		_, err := client.GetFeature(ctx, knownPoint)
+		return err
+	}
+}
+
+func resumeH2C(tb testing.TB, mode resumeMode, _ *benchmark.TLSConfig, dials *dialCounter) func(context.Context) error {
+	h2s := &http2.Server{IdleTimeout: mode.timeout}
+	endpoint := resumeServe(tb, &http.Server{
+		Handler:     h2c.NewHandler(benchmark.NewHTTPHandler(server.NewRouteGuideServer()), h2s),
+		IdleTimeout: mode.timeout,
+	})
+	return resumeClient(tb, "http://"+endpoint, &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dials.DialContext(ctx, network, addr)
+		},
+		ReadIdleTimeout: mode.ping,
+	})
+}
+
+func resumeHTTP1(tb testing.TB, mode resumeMode, _ *benchmark.TLSConfig, dials *dialCounter) func(context.Context) error {
+	endpoint := resumeServe(tb, &http.Server{
+		Handler:     benchmark.NewHTTPHandler(server.NewRouteGuideServer()),
+		IdleTimeout: mode.timeout,
+	})
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dials.DialContext
+	return resumeClient(tb, "http://"+endpoint, transport)
+}
+
+func resumeHTTPS(tb testing.TB, mode resumeMode, conf *benchmark.TLSConfig,
+	dials *dialCounter) func(context.Context) error {
+	endpoint := resumeServe(tb, &http.Server{
+		Handler:     benchmark.NewHTTPHandler(server.NewRouteGuideServer()),
+		TLSConfig:   conf.ServerTLS,
+		IdleTimeout: mode.timeout,
+	})
+	return resumeClient(tb, "https://"+endpoint, &http2.Transport{
+		TLSClientConfig: conf.ClientTLS,
+		DialTLSContext:  dials.DialTLSContext,
+		ReadIdleTimeout: mode.ping,
+	})
+}
+
+// resumeServe serves srv on an ephemeral port, with TLS if srv.TLSConfig is set, and returns its address
+func resumeServe(tb testing.TB, srv *http.Server) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go func() {
+		if srv.TLSConfig != nil {
+			_ = srv.ServeTLS(l, "", "")
+			return
+		}
+		_ = srv.Serve(l)
+	}()
+	tb.Cleanup(func() { _ = srv.Close() })
+	return l.Addr().String()
+}
+
+func resumeClient(tb testing.TB, endpoint string, transport interface {
+	http.RoundTripper
+	CloseIdleConnections()
+}) func(context.Context) error {
+	tb.Cleanup(transport.CloseIdleConnections)
+	client := benchmark.NewClient(&http.Client{Transport: transport}, endpoint)
+	return func(ctx context.Context) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, knownPoint, &resp)
+	}
+}
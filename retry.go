@@ -0,0 +1,116 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+)
+
+// DefaultRetryableCodes are the codes retried when RetryPolicy.RetryableCodes is empty. CodeClientError
+// is returned when the request could not be sent, for example because the connection was refused.
+var DefaultRetryableCodes = []int{
+	duh.CodeTooManyRequests,
+	duh.CodeClientError,
+	502, // Bad Gateway
+	503, // Service Unavailable
+	504, // Gateway Timeout
+}
+
+// RetryPolicy configures how HTTPClient retries failed calls, similar to the retry policy of
+// a gRPC service config.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first, values less than 2 disable retries
+	MaxAttempts int
+
+	// InitialBackoff is the time to wait before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the longest time to wait between attempts, zero does not limit the backoff
+	MaxBackoff time.Duration
+
+	// BackoffMultiplier is multiplied with the backoff after each retry, values less than 1 are treated as 1
+	BackoffMultiplier float64
+
+	// Jitter is the fraction of each backoff which is randomized, 0.2 waits between 80% and 120% of
+	// the backoff. Jitter avoids many clients retrying at the same time.
+	Jitter float64
+
+	// (Optional) PerAttemptTimeout is the deadline of each attempt. An attempt which times out is
+	// retried if the context of the call has not expired.
+	PerAttemptTimeout time.Duration
+
+	// (Optional) RetryableCodes are the codes of the errors which are retried. Defaults to DefaultRetryableCodes
+	RetryableCodes []int
+}
+
+// Do calls call until it succeeds, returns an error which should not be retried, the policy runs
+// out of attempts or the context is done. It returns the error from the last attempt.
+func (p RetryPolicy) Do(ctx context.Context, call func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		timedOut, err := p.attempt(ctx, call)
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		if !timedOut && !p.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// attempt calls call with the per attempt timeout and reports if the attempt timed out
+func (p RetryPolicy) attempt(ctx context.Context, call func(context.Context) error) (bool, error) {
+	if p.PerAttemptTimeout == 0 {
+		return false, call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.PerAttemptTimeout)
+	defer cancel()
+	err := call(ctx)
+	return err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded), err
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	var de duh.Error
+	if !errors.As(err, &de) {
+		return false
+	}
+	codes := p.RetryableCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryableCodes
+	}
+	for _, c := range codes {
+		if de.Code() == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the time to wait after the attempt, including jitter
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || backoff < float64(p.MaxBackoff)); i++ {
+		backoff *= multiplier
+	}
+	if p.MaxBackoff != 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
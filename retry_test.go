@@ -0,0 +1,268 @@
+package benchmark_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// faultHandler injects a fault into every request for which fail returns true, otherwise the
+// request is passed to the RouteGuide handler
+type faultHandler struct {
+	next     http.Handler
+	requests atomic.Int64
+	fail     func(n int64) bool
+	fault    func(w http.ResponseWriter, r *http.Request)
+}
+
+func (h *faultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.fail(h.requests.Add(1)) {
+		h.fault(w, r)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+func firstN(n int64) func(int64) bool { return func(i int64) bool { return i <= n } }
+
+func always(int64) bool { return true }
+
+func replyCode(code int) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		duh.ReplyWithCode(w, r, code, nil, "injected fault")
+	}
+}
+
+// unavailable replies like a load balancer with no healthy backends
+func unavailable(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, "no healthy upstream", http.StatusServiceUnavailable)
+}
+
+// hang waits until the client abandons the request. The body must be read before the server
+// notices the client has closed the connection.
+func hang(_ http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	<-r.Context().Done()
+}
+
+func TestRetryPolicy(t *testing.T) {
+	fast := benchmark.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond,
+		BackoffMultiplier: 2}
+
+	for _, test := range []struct {
+		name     string
+		policy   benchmark.RetryPolicy
+		fail     func(int64) bool
+		fault    func(http.ResponseWriter, *http.Request)
+		timeout  time.Duration
+		attempts int64
+		wantCode int
+	}{
+		{name: "RetriesUntilSuccess", policy: fast, fail: firstN(2), fault: unavailable, attempts: 3},
+		{name: "GivesUpAfterMaxAttempts", policy: fast, fail: always, fault: unavailable, attempts: 3,
+			wantCode: http.StatusServiceUnavailable},
+		{name: "TooManyRequests", policy: fast, fail: firstN(1), fault: replyCode(duh.CodeTooManyRequests),
+			attempts: 2},
+		{name: "NonRetryableCode", policy: fast, fail: always, fault: replyCode(duh.CodeInternalError), attempts: 1,
+			wantCode: duh.CodeInternalError},
+		{name: "SelectedCodes", fail: firstN(1), fault: replyCode(duh.CodeInternalError), attempts: 2,
+			policy: benchmark.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond,
+				RetryableCodes: []int{duh.CodeInternalError}}},
+		{name: "PerAttemptTimeout", fail: firstN(1), fault: hang, attempts: 2,
+			policy: benchmark.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond,
+				PerAttemptTimeout: 50 * time.Millisecond}},
+		{name: "ContextDeadline", fail: always, fault: unavailable, timeout: 50 * time.Millisecond, attempts: 1,
+			policy:   benchmark.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute},
+			wantCode: http.StatusServiceUnavailable},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := &faultHandler{
+				next:  benchmark.NewHTTPHandler(server.NewRouteGuideServer()),
+				fail:  test.fail,
+				fault: test.fault,
+			}
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			client := benchmark.NewClient(srv.Client(), srv.URL, benchmark.WithRetryPolicy(test.policy))
+
+			ctx := context.Background()
+			if test.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			var resp pb.Feature
+			err := client.GetFeature(ctx, knownPoint, &resp)
+			if test.wantCode == 0 && err != nil {
+				t.Fatalf("expected success; got '%s'", err)
+			}
+			if test.wantCode != 0 {
+				var de duh.Error
+				if !errors.As(err, &de) || de.Code() != test.wantCode {
+					t.Fatalf("expected error with code '%d'; got '%v'", test.wantCode, err)
+				}
+			}
+			if got := h.requests.Load(); got != test.attempts {
+				t.Errorf("expected '%d' attempts; got '%d'", test.attempts, got)
+			}
+			// The backoff must not outlive the context
+			if test.timeout != 0 && time.Since(start) > time.Second {
+				t.Errorf("expected retries to stop when the context expired; took '%s'", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		policy   benchmark.RetryPolicy
+		expected []time.Duration
+	}{
+		{name: "MaxBackoff", policy: benchmark.RetryPolicy{InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff: time.Second, BackoffMultiplier: 2}, expected: []time.Duration{100, 200, 400, 800, 1000, 1000}},
+		{name: "NoMaxBackoff", policy: benchmark.RetryPolicy{InitialBackoff: 100 * time.Millisecond,
+			BackoffMultiplier: 2}, expected: []time.Duration{100, 200, 400, 800, 1600, 3200}},
+		{name: "NoMultiplier", policy: benchmark.RetryPolicy{InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff: time.Second}, expected: []time.Duration{100, 100, 100}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for attempt, expected := range test.expected {
+				if got := test.policy.Backoff(attempt + 1); got != expected*time.Millisecond {
+					t.Errorf("attempt %d: expected backoff '%s'; got '%s'", attempt+1, expected*time.Millisecond, got)
+				}
+			}
+		})
+	}
+
+	p := benchmark.RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, BackoffMultiplier: 2,
+		Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(2); got < 160*time.Millisecond || got > 240*time.Millisecond {
+			t.Fatalf("expected backoff within 20%% of '200ms'; got '%s'", got)
+		}
+	}
+}
+
+// BenchmarkRetry measures the overhead of a retry policy when no call fails, and the cost of a
+// call which fails once and is retried, for HTTPClient and the gRPC retry policy.
+func BenchmarkRetry(b *testing.B) {
+	policy := benchmark.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Microsecond, MaxBackoff: time.Millisecond,
+		BackoffMultiplier: 2}
+	// Equivalent to the policy above, see https://github.com/grpc/proposal/blob/master/A6-client-retries.md
+	serviceConfig := `{"methodConfig": [{"name": [{"service": "routeguide.RouteGuide"}], "retryPolicy": {
+		"MaxAttempts": 4, "InitialBackoff": "0.000001s", "MaxBackoff": "0.001s", "BackoffMultiplier": 2,
+		"RetryableStatusCodes": ["UNAVAILABLE"]}}]}`
+
+	for _, fault := range []struct {
+		name string
+		fail func(int64) bool
+	}{
+		{name: "NoFaults", fail: func(int64) bool { return false }},
+		// Every odd request fails, so every call is retried exactly once
+		{name: "RetryOnce", fail: func(n int64) bool { return n%2 == 1 }},
+	} {
+		b.Run("GRPC/"+fault.name, func(b *testing.B) {
+			for _, retry := range []bool{false, true} {
+				name := "NoPolicy"
+				if retry {
+					name = "Policy"
+				}
+				b.Run(name, func(b *testing.B) {
+					if !retry && fault.name == "RetryOnce" {
+						b.Skip("calls fail without a retry policy")
+					}
+					var requests atomic.Int64
+					listener, err := net.Listen("tcp", "localhost:0")
+					if err != nil {
+						b.Fatal(err)
+					}
+					grpcServer := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any,
+						_ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+						if fault.fail(requests.Add(1)) {
+							return nil, status.Error(codes.Unavailable, "injected fault")
+						}
+						return handler(ctx, req)
+					}))
+					pb.RegisterRouteGuideServer(grpcServer, server.NewRouteGuideServer())
+					go func() { _ = grpcServer.Serve(listener) }()
+					defer grpcServer.Stop()
+
+					opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock()}
+					if retry {
+						opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+					} else {
+						opts = append(opts, grpc.WithDisableRetry())
+					}
+					conn, err := grpc.Dial(listener.Addr().String(), opts...)
+					if err != nil {
+						b.Fatal(err)
+					}
+					defer func() { _ = conn.Close() }()
+					client := pb.NewRouteGuideClient(conn)
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for n := 0; n < b.N; n++ {
+						if _, err := client.GetFeature(context.Background(), knownPoint); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+
+		b.Run("HTTP/"+fault.name, func(b *testing.B) {
+			for _, retry := range []bool{false, true} {
+				name := "NoPolicy"
+				if retry {
+					name = "Policy"
+				}
+				b.Run(name, func(b *testing.B) {
+					if !retry && fault.name == "RetryOnce" {
+						b.Skip("calls fail without a retry policy")
+					}
+					h := &faultHandler{
+						next:  benchmark.NewHTTPHandler(server.NewRouteGuideServer()),
+						fail:  fault.fail,
+						fault: unavailable,
+					}
+					srv := httptest.NewServer(h)
+					defer srv.Close()
+
+					var opts []benchmark.ClientOption
+					if retry {
+						opts = append(opts, benchmark.WithRetryPolicy(policy))
+					}
+					client := benchmark.NewClient(srv.Client(), srv.URL, opts...)
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for n := 0; n < b.N; n++ {
+						var resp pb.Feature
+						if err := client.GetFeature(context.Background(), knownPoint, &resp); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package benchmark_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var (
+	rotate = flag.Bool("rotate", false, "run BenchmarkCertRotation, which rotates the server certificate "+
+		"every -rotate-every while calling HTTPS and gRPC with TLS")
+	rotateEvery = flag.Duration("rotate-every", 100*time.Millisecond, "interval between certificate "+
+		"rotations in BenchmarkCertRotation")
+	rotateCheck = flag.Duration("rotate-check", 10*time.Millisecond, "how often the servers in "+
+		"BenchmarkCertRotation check for a new certificate")
+)
+
+// rotateDial returns a new client of the server and a func which closes it
+type rotateDial func() (getFeature func(context.Context) error, closer func())
+
+// rotateTransports serve the service on the listener with the TLS config and return a func which
+// dials a new client of the server
+var rotateTransports = map[string]func(tb testing.TB, l net.Listener, conf *benchmark.TLSConfig) rotateDial{
+	"GRPC":  rotateGRPC,
+	"HTTPS": rotateHTTPS,
+}
+
+func TestCertReload(t *testing.T) {
+	var ca benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&ca); err != nil {
+		t.Fatal(err)
+	}
+	rotation := newCertRotation(t, &ca)
+	conf := benchmark.TLSConfig{
+		CaPEM:              ca.CaPEM,
+		CertFile:           rotation.certFile,
+		KeyFile:            rotation.keyFile,
+		CertReloadInterval: time.Millisecond,
+	}
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		t.Fatal(err)
+	}
+	var handshakes atomic.Int64
+	conf.ClientTLS.VerifyConnection = func(tls.ConnectionState) error {
+		handshakes.Add(1)
+		return nil
+	}
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	getFeature, closer := rotateHTTPS(t, l, &conf)()
+	defer closer()
+	ctx := context.Background()
+	if err := getFeature(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// peerCert returns the certificate a new connection is established with
+	peerCert := func() []byte {
+		t.Helper()
+		c, err := tls.Dial("tcp", l.Addr().String(), conf.ClientTLS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = c.Close() }()
+		return c.ConnectionState().PeerCertificates[0].Raw
+	}
+	if !bytes.Equal(peerCert(), rotation.leaf) {
+		t.Fatal("expected the certificate in the files")
+	}
+
+	// Established connections keep their certificate, new connections use the new one
+	time.Sleep(20 * time.Millisecond)
+	if err := rotation.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	before := handshakes.Load()
+	if err := getFeature(ctx); err != nil {
+		t.Fatalf("expected the established connection to survive the rotation; got '%v'", err)
+	}
+	if handshakes.Load() != before {
+		t.Error("expected the call to use the established connection")
+	}
+	if !bytes.Equal(peerCert(), rotation.leaf) {
+		t.Error("expected new connections to use the rotated certificate")
+	}
+	if conf.CertReloader.Reloads() != 1 {
+		t.Errorf("expected '1' reload; got '%d'", conf.CertReloader.Reloads())
+	}
+
+	// A certificate whose key has not been written yet is not used
+	previous := rotation.leaf
+	certPEM, keyPEM, leaf, err := rotation.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := rotation.write(rotation.certFile, certPEM); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !bytes.Equal(peerCert(), previous) {
+		t.Error("expected the previous certificate until the key is written")
+	}
+	if err := rotation.write(rotation.keyFile, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !bytes.Equal(peerCert(), leaf) {
+		t.Error("expected the certificate once its key is written")
+	}
+}
+
+// BenchmarkCertRotation calls HTTPS and gRPC with TLS while the server certificate is rotated every
+// -rotate-every, and reports the errors and TLS handshakes per call. Each transport either reuses an
+// established connection, which keeps the certificate it was established with, or dials a new
+// connection for every call, which handshakes with whichever certificate is current. The Static
+// scenarios check for new certificates without rotating them.
+func BenchmarkCertRotation(b *testing.B) {
+	if !*rotate {
+		b.Skip("pass -rotate to benchmark certificate rotation")
+	}
+	var ca benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&ca); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, transport := range []string{"GRPC", "HTTPS"} {
+		for _, conn := range []string{"Reuse", "New"} {
+			for _, rotating := range []bool{false, true} {
+				name := transport + "/" + conn + "/Static"
+				if rotating {
+					name = transport + "/" + conn + "/Rotate"
+				}
+				b.Run(name, func(b *testing.B) {
+					runCertRotation(b, &ca, transport, conn == "New", rotating)
+				})
+			}
+		}
+	}
+}
+
+func runCertRotation(b *testing.B, ca *benchmark.TLSConfig, transport string, newConns, rotating bool) {
+	rotation := newCertRotation(b, ca)
+	conf := benchmark.TLSConfig{
+		CaPEM:              ca.CaPEM,
+		CertFile:           rotation.certFile,
+		KeyFile:            rotation.keyFile,
+		CertReloadInterval: *rotateCheck,
+	}
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		b.Fatal(err)
+	}
+	var handshakes atomic.Int64
+	conf.ClientTLS.VerifyConnection = func(tls.ConnectionState) error {
+		handshakes.Add(1)
+		return nil
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	dial := rotateTransports[transport](b, l, &conf)
+	shared, closer := dial()
+	defer closer()
+	ctx := context.Background()
+	if err := shared(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	var rotations int
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	if rotating {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(*rotateEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if err := rotation.rotate(); err != nil {
+						b.Error(err)
+						return
+					}
+					rotations++
+				}
+			}
+		}()
+	}
+
+	var errs atomic.Int64
+	before := handshakes.Load()
+	b.ResetTimer()
+	b.RunParallel(func(p *testing.PB) {
+		for p.Next() {
+			getFeature, closer := shared, func() {}
+			if newConns {
+				getFeature, closer = dial()
+			}
+			if err := getFeature(ctx); err != nil {
+				errs.Add(1)
+			}
+			closer()
+		}
+	})
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+
+	b.ReportMetric(float64(errs.Load())/float64(b.N), "errors/op")
+	b.ReportMetric(float64(handshakes.Load()-before)/float64(b.N), "handshakes/op")
+	b.ReportMetric(float64(rotations), "rotations")
+	b.ReportMetric(float64(conf.CertReloader.Reloads()), "reloads")
+}
+
+// certRotation writes key pairs signed by the CA into a certificate and key file
+type certRotation struct {
+	ca       *benchmark.TLSConfig
+	certFile string
+	keyFile  string
+	// leaf is the certificate last written
+	leaf []byte
+}
+
+// newCertRotation writes the first key pair into a temporary directory
+func newCertRotation(tb testing.TB, ca *benchmark.TLSConfig) *certRotation {
+	dir := tb.TempDir()
+	r := &certRotation{
+		ca:       ca,
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "cert.key"),
+	}
+	if err := r.rotate(); err != nil {
+		tb.Fatal(err)
+	}
+	return r
+}
+
+// rotate writes a new key pair, the certificate first
+func (r *certRotation) rotate() error {
+	certPEM, keyPEM, leaf, err := r.generate()
+	if err != nil {
+		return err
+	}
+	if err := r.write(r.certFile, certPEM); err != nil {
+		return err
+	}
+	if err := r.write(r.keyFile, keyPEM); err != nil {
+		return err
+	}
+	r.leaf = leaf
+	return nil
+}
+
+// generate returns a new key pair signed by the CA, and the DER of its certificate
+func (r *certRotation) generate() ([]byte, []byte, []byte, error) {
+	conf := benchmark.TLSConfig{CaPEM: r.ca.CaPEM, CaKeyPEM: r.ca.CaKeyPEM}
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		return nil, nil, nil, err
+	}
+	block, _ := pem.Decode(conf.CertPEM.Bytes())
+	if block == nil {
+		return nil, nil, nil, errors.New("no certificate in the generated PEM")
+	}
+	return conf.CertPEM.Bytes(), conf.KeyPEM.Bytes(), block.Bytes, nil
+}
+
+// write renames a temporary file over the file, so a reload never reads a partial file
+func (r *certRotation) write(name string, b []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func rotateGRPC(tb testing.TB, l net.Listener, conf *benchmark.TLSConfig) rotateDial {
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(conf.ServerTLS)))
+	pb.RegisterRouteGuideServer(srv, server.NewRouteGuideServer())
+	go func() { _ = srv.Serve(l) }()
+	tb.Cleanup(srv.Stop)
+
+	return func() (func(context.Context) error, func()) {
+		conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(conf.ClientTLS)))
+		if err != nil {
+			return func(context.Context) error { return err }, func() {}
+		}
+		client := pb.NewRouteGuideClient(conn)
+		return func(ctx context.Context) error {
+			_, err := client.GetFeature(ctx, knownPoint)
+			return err
+		}, func() { _ = conn.Close() }
+	}
+}
+
+func rotateHTTPS(tb testing.TB, l net.Listener, conf *benchmark.TLSConfig) rotateDial {
+	srv := &http.Server{
+		Handler:   benchmark.NewHTTPHandler(server.NewRouteGuideServer()),
+		TLSConfig: conf.ServerTLS,
+	}
+	go func() { _ = srv.ServeTLS(l, "", "") }()
+	tb.Cleanup(func() { _ = srv.Close() })
+	endpoint := "https://" + l.Addr().String()
+
+	return func() (func(context.Context) error, func()) {
+		transport := &http2.Transport{TLSClientConfig: conf.ClientTLS}
+		client := benchmark.NewClient(&http.Client{Transport: transport}, endpoint)
+		return func(ctx context.Context) error {
+			var resp pb.Feature
+			return client.GetFeature(ctx, knownPoint, &resp)
+		}, transport.CloseIdleConnections
+	}
+}
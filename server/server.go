@@ -25,42 +25,214 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
 	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorMode determines how GetFeature responds when no feature exists at the requested point.
+type ErrorMode int32
+
+const (
+	// ErrorModeNone responds with an unnamed feature, this is the default.
+	ErrorModeNone ErrorMode = iota
+	// ErrorModeNotFound responds with a not found error which includes only a message.
+	ErrorModeNotFound
+	// ErrorModeNotFoundWithDetails responds with a not found error which includes details about the request.
+	ErrorModeNotFoundWithDetails
+)
+
+// Option configures the RouteGuideService returned by NewRouteGuideServer()
+type Option func(*RouteGuideService)
+
+// WithErrorMode sets the initial ErrorMode of the service. See RouteGuideService.SetErrorMode()
+func WithErrorMode(mode ErrorMode) Option {
+	return func(s *RouteGuideService) {
+		s.SetErrorMode(mode)
+	}
+}
+
+// WithClock sets the clock used to time routes in RecordRoute, defaults to the system clock
+func WithClock(clock Clock) Option {
+	return func(s *RouteGuideService) {
+		s.clock = clock
+	}
+}
+
+// Clock provides the current time, it allows tests to control the time observed by the service
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// NotFoundError is returned by FindFeature when no feature exists at the requested point and the
+// service is not in ErrorModeNone. It is transport neutral, each transport is responsible for
+// converting it into its own error representation.
+type NotFoundError struct {
+	// Point is the point requested
+	Point *pb.Point
+	// Details is only populated when the service is in ErrorModeNotFoundWithDetails
+	Details map[string]string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no feature found at point (%d, %d)", e.Point.Latitude, e.Point.Longitude)
+}
+
+const (
+	ErrorReasonFeatureNotFound = "FEATURE_NOT_FOUND"
+	ErrorDomain                = "routeguide.duh-rpc.org"
 )
 
 type RouteGuideService struct {
 	pb.UnimplementedRouteGuideServer
 	savedFeatures []*pb.Feature // read-only after initialized
+	clock         Clock
+	errorMode     atomic.Int32
+	latency       atomic.Int64
+	canceled      atomic.Int64
+	replyName     atomic.Pointer[string]
 
 	mu         sync.Mutex // protects routeNotes
 	routeNotes map[string][]*pb.RouteNote
 }
 
-// GetFeature returns the feature at the given point.
-func (s *RouteGuideService) GetFeature(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
+// SetErrorMode changes how the service responds when no feature exists at the requested point.
+// It is safe to call while the service is handling requests.
+func (s *RouteGuideService) SetErrorMode(mode ErrorMode) {
+	s.errorMode.Store(int32(mode))
+}
+
+// SetLatency adds an artificial delay to every FindFeature call which simulates a slow backend.
+// The delay is abandoned and the context error returned if the request context is done before
+// the delay expires. It is safe to call while the service is handling requests.
+func (s *RouteGuideService) SetLatency(d time.Duration) {
+	s.latency.Store(int64(d))
+}
+
+// SetReplySize replaces the name of every feature returned by FindFeature and ListFeatures with a
+// name of n bytes, which simulates large replies. Zero returns the features unchanged. It is safe
+// to call while the service is handling requests.
+func (s *RouteGuideService) SetReplySize(n int) {
+	if n <= 0 {
+		s.replyName.Store(nil)
+		return
+	}
+	// Every reply shares the same name, so large replies do not allocate on every call
+	name := strings.Repeat("x", n)
+	s.replyName.Store(&name)
+}
+
+// reply returns the feature, with the name set by SetReplySize() if there is one
+func (s *RouteGuideService) reply(feature *pb.Feature) *pb.Feature {
+	name := s.replyName.Load()
+	if name == nil {
+		return feature
+	}
+	return &pb.Feature{Name: *name, Location: feature.Location}
+}
+
+// CanceledCount returns the number of FindFeature calls which stopped work early because the
+// request context was canceled or the deadline was exceeded.
+func (s *RouteGuideService) CanceledCount() int64 {
+	return s.canceled.Load()
+}
+
+// FindFeature returns the feature at the given point. If no feature exists at the point, then the
+// reply depends on the current ErrorMode; either an unnamed feature or a *NotFoundError.
+func (s *RouteGuideService) FindFeature(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
+	if d := time.Duration(s.latency.Load()); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			s.canceled.Add(1)
+			return nil, ctx.Err()
+		}
+	}
+
 	for _, feature := range s.savedFeatures {
 		if proto.Equal(feature.Location, point) {
-			return feature, nil
+			return s.reply(feature), nil
+		}
+	}
+
+	switch ErrorMode(s.errorMode.Load()) {
+	case ErrorModeNotFound:
+		return nil, &NotFoundError{Point: point}
+	case ErrorModeNotFoundWithDetails:
+		return nil, &NotFoundError{
+			Point: point,
+			Details: map[string]string{
+				"reason":    ErrorReasonFeatureNotFound,
+				"latitude":  strconv.FormatInt(int64(point.Latitude), 10),
+				"longitude": strconv.FormatInt(int64(point.Longitude), 10),
+			},
 		}
 	}
 	// No feature was found, return an unnamed feature
 	return &pb.Feature{Location: point}, nil
 }
 
+// GetFeature returns the feature at the given point.
+func (s *RouteGuideService) GetFeature(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
+	feature, err := s.FindFeature(ctx, point)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return feature, nil
+}
+
+// toStatusError converts errors returned by FindFeature into gRPC status errors
+func toStatusError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		return status.Errorf(codes.Internal, "%s", err)
+	}
+
+	if nf.Details == nil {
+		return status.Errorf(codes.NotFound, "no feature found at point (%d, %d)",
+			nf.Point.Latitude, nf.Point.Longitude)
+	}
+
+	st, dErr := status.New(codes.NotFound, nf.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason:   ErrorReasonFeatureNotFound,
+		Domain:   ErrorDomain,
+		Metadata: nf.Details,
+	})
+	if dErr != nil {
+		return status.Errorf(codes.Internal, "while attaching error details: %s", dErr)
+	}
+	return st.Err()
+}
+
 // ListFeatures lists all features contained within the given bounding Rectangle.
 func (s *RouteGuideService) ListFeatures(rect *pb.Rectangle, stream pb.RouteGuide_ListFeaturesServer) error {
 	for _, feature := range s.savedFeatures {
 		if inRange(feature.Location, rect) {
-			if err := stream.Send(feature); err != nil {
+			if err := stream.Send(s.reply(feature)); err != nil {
 				return err
 			}
 		}
@@ -76,16 +248,17 @@ func (s *RouteGuideService) ListFeatures(rect *pb.Rectangle, stream pb.RouteGuid
 func (s *RouteGuideService) RecordRoute(stream pb.RouteGuide_RecordRouteServer) error {
 	var pointCount, featureCount, distance int32
 	var lastPoint *pb.Point
-	startTime := time.Now()
+	startTime := s.clock.Now()
 	for {
 		point, err := stream.Recv()
 		if err == io.EOF {
-			endTime := time.Now()
+			elapsed := s.clock.Now().Sub(startTime)
 			return stream.SendAndClose(&pb.RouteSummary{
-				PointCount:   pointCount,
-				FeatureCount: featureCount,
-				Distance:     distance,
-				ElapsedTime:  int32(endTime.Sub(startTime).Seconds()),
+				PointCount:    pointCount,
+				FeatureCount:  featureCount,
+				Distance:      distance,
+				ElapsedTime:   int32(elapsed.Seconds()),
+				ElapsedTimeNs: elapsed.Nanoseconds(),
 			})
 		}
 		if err != nil {
@@ -195,9 +368,15 @@ func serialize(point *pb.Point) string {
 	return fmt.Sprintf("%d %d", point.Latitude, point.Longitude)
 }
 
-func NewRouteGuideServer() *RouteGuideService {
-	s := &RouteGuideService{routeNotes: make(map[string][]*pb.RouteNote)}
+func NewRouteGuideServer(opts ...Option) *RouteGuideService {
+	s := &RouteGuideService{
+		routeNotes: make(map[string][]*pb.RouteNote),
+		clock:      systemClock{},
+	}
 	s.loadFeatures("")
+	for _, opt := range opts {
+		opt(s)
+	}
 	return s
 }
 
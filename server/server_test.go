@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"google.golang.org/grpc"
+)
+
+// stepClock advances by step every time Now() is called
+type stepClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// recordRouteStream is a fake pb.RouteGuide_RecordRouteServer which sends the provided points
+type recordRouteStream struct {
+	grpc.ServerStream
+	points  []*pb.Point
+	summary *pb.RouteSummary
+}
+
+func (s *recordRouteStream) Recv() (*pb.Point, error) {
+	if len(s.points) == 0 {
+		return nil, io.EOF
+	}
+	p := s.points[0]
+	s.points = s.points[1:]
+	return p, nil
+}
+
+func (s *recordRouteStream) SendAndClose(summary *pb.RouteSummary) error {
+	s.summary = summary
+	return nil
+}
+
+func TestCalcDistance(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		p1, p2   *pb.Point
+		expected int32
+	}{
+		{
+			name:     "SamePoint",
+			p1:       &pb.Point{Latitude: 409146138, Longitude: -746188906},
+			p2:       &pb.Point{Latitude: 409146138, Longitude: -746188906},
+			expected: 0,
+		},
+		{
+			// One degree of longitude on the equator is 6371000 * π / 180 metres
+			name:     "OneDegreeLongitude",
+			p1:       &pb.Point{Latitude: 0, Longitude: 0},
+			p2:       &pb.Point{Latitude: 0, Longitude: 1e7},
+			expected: 111194,
+		},
+		{
+			name:     "OneDegreeLatitude",
+			p1:       &pb.Point{Latitude: 0, Longitude: 0},
+			p2:       &pb.Point{Latitude: -1e7, Longitude: 0},
+			expected: 111194,
+		},
+		{
+			// Half way around the earth is 6371000 * π metres
+			name:     "Antipodes",
+			p1:       &pb.Point{Latitude: 0, Longitude: 0},
+			p2:       &pb.Point{Latitude: 0, Longitude: 180e7},
+			expected: 20015086,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calcDistance(tt.p1, tt.p2); got != tt.expected {
+				t.Errorf("expected distance '%d'; got '%d'", tt.expected, got)
+			}
+			if got := calcDistance(tt.p2, tt.p1); got != tt.expected {
+				t.Errorf("expected reversed distance '%d'; got '%d'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordRoute(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		step     time.Duration
+		points   []*pb.Point
+		expected *pb.RouteSummary
+	}{
+		{
+			name:     "NoPoints",
+			step:     time.Second,
+			expected: &pb.RouteSummary{ElapsedTime: 1, ElapsedTimeNs: int64(time.Second)},
+		},
+		{
+			name: "SubSecondRoute",
+			step: 250 * time.Millisecond,
+			points: []*pb.Point{
+				{Latitude: 0, Longitude: 0},
+				{Latitude: 0, Longitude: 1e7},
+			},
+			expected: &pb.RouteSummary{
+				PointCount:    2,
+				FeatureCount:  1,
+				Distance:      111194,
+				ElapsedTime:   0,
+				ElapsedTimeNs: int64(250 * time.Millisecond),
+			},
+		},
+		{
+			name: "MultipleFeatures",
+			step: 1500 * time.Millisecond,
+			points: []*pb.Point{
+				{Latitude: 0, Longitude: 0},
+				{Latitude: 0, Longitude: 1e7},
+				{Latitude: 0, Longitude: 2e7},
+				{Latitude: 0, Longitude: 1e7},
+			},
+			expected: &pb.RouteSummary{
+				PointCount:    4,
+				FeatureCount:  2,
+				Distance:      3 * 111194,
+				ElapsedTime:   1,
+				ElapsedTimeNs: int64(1500 * time.Millisecond),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRouteGuideServer(WithClock(&stepClock{now: time.Unix(1696262400, 0), step: tt.step}))
+			s.savedFeatures = []*pb.Feature{
+				{Name: "One Degree East", Location: &pb.Point{Latitude: 0, Longitude: 1e7}},
+			}
+
+			stream := &recordRouteStream{points: tt.points}
+			if err := s.RecordRoute(stream); err != nil {
+				t.Fatal(err)
+			}
+
+			got := stream.summary
+			if got.PointCount != tt.expected.PointCount {
+				t.Errorf("expected PointCount '%d'; got '%d'", tt.expected.PointCount, got.PointCount)
+			}
+			if got.FeatureCount != tt.expected.FeatureCount {
+				t.Errorf("expected FeatureCount '%d'; got '%d'", tt.expected.FeatureCount, got.FeatureCount)
+			}
+			if got.Distance != tt.expected.Distance {
+				t.Errorf("expected Distance '%d'; got '%d'", tt.expected.Distance, got.Distance)
+			}
+			if got.ElapsedTime != tt.expected.ElapsedTime {
+				t.Errorf("expected ElapsedTime '%d'; got '%d'", tt.expected.ElapsedTime, got.ElapsedTime)
+			}
+			if got.ElapsedTimeNs != tt.expected.ElapsedTimeNs {
+				t.Errorf("expected ElapsedTimeNs '%d'; got '%d'", tt.expected.ElapsedTimeNs, got.ElapsedTimeNs)
+			}
+		})
+	}
+}
+
+func TestSetReplySize(t *testing.T) {
+	s := NewRouteGuideServer()
+	s.savedFeatures = []*pb.Feature{
+		{Name: "One Degree East", Location: &pb.Point{Latitude: 0, Longitude: 1e7}},
+	}
+	point := &pb.Point{Latitude: 0, Longitude: 1e7}
+
+	s.SetReplySize(1024)
+	feature, err := s.FindFeature(context.Background(), point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feature.Name) != 1024 || feature.Location.Longitude != 1e7 {
+		t.Errorf("expected a 1024 byte name at '%v'; got %d bytes at '%v'", point, len(feature.Name), feature.Location)
+	}
+	if s.savedFeatures[0].Name != "One Degree East" {
+		t.Errorf("expected the saved feature to be unchanged; got '%s'", s.savedFeatures[0].Name)
+	}
+
+	s.SetReplySize(0)
+	if feature, _ = s.FindFeature(context.Background(), point); feature.Name != "One Degree East" {
+		t.Errorf("expected 'One Degree East'; got '%s'", feature.Name)
+	}
+}
@@ -0,0 +1,224 @@
+package benchmark_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+)
+
+// serverFlags are the routeguide-server flags which serve the transport of each benchmark
+var serverFlags = map[string]string{
+	"GRPC":      "grpc",
+	"GRPCTuned": "grpc-tuned",
+	"GRPCTLS":   "grpc-tls",
+	"HTTP2":     "h2c",
+	"HTTP1":     "http1",
+	"HTTPS":     "https",
+	"FastHTTP":  "fasthttp",
+	"Hertz":     "hertz",
+}
+
+// uncounted are the transports whose server cannot serve on the listener returned by
+// ConnStats.Listener(), so the traffic of the server is not counted
+var uncounted = map[string]bool{
+	"Hertz": true,
+}
+
+// adminPrefix prefixes the last line routeguide-server writes to stdout once it is ready
+const adminPrefix = "admin: "
+
+// tlsFiles are the PEM files routeguide-server reads from its `-tls-dir`
+var tlsFiles = []struct {
+	name string
+	pem  func(conf *benchmark.TLSConfig) **bytes.Buffer
+}{
+	{name: "ca.pem", pem: func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CaPEM }},
+	{name: "ca.key", pem: func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CaKeyPEM }},
+	{name: "cert.pem", pem: func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.CertPEM }},
+	{name: "cert.key", pem: func(c *benchmark.TLSConfig) **bytes.Buffer { return &c.KeyPEM }},
+}
+
+// serverSide configures and measures the RouteGuideService under test
+type serverSide interface {
+	SetScenario(context.Context, benchmark.Scenario) error
+	// Stats returns the resources consumed by the server. The usage is only separate from the
+	// client when the server runs in its own process.
+	Stats(context.Context) (benchmark.ServerStats, error)
+	Close()
+}
+
+// startServer starts the server for the benchmark on the address, in a child process if
+// `-server-process` was provided.
+func startServer(b *testing.B, address string, conf *benchmark.TLSConfig) serverSide {
+	transport := strings.TrimPrefix(b.Name(), "Benchmark")
+	if *serverProcess {
+		side, err := spawnServer(b, transport, address, conf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return side
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		b.Fatalf("failed to listen: %v", err)
+	}
+	svc := server.NewRouteGuideServer()
+	conns := newConnStats()
+	l := conns.Listener(listener)
+	if uncounted[transport] {
+		l = listener
+	}
+	return &localServer{
+		svc:      svc,
+		conns:    conns,
+		listener: listener,
+		stop:     servers[transport](l, svc, conf),
+	}
+}
+
+// localServer is a RouteGuideService running in the benchmark process
+type localServer struct {
+	svc      *server.RouteGuideService
+	conns    *benchmark.ConnStats
+	listener net.Listener
+	stop     func()
+}
+
+func (s *localServer) SetScenario(_ context.Context, sc benchmark.Scenario) error {
+	s.svc.SetErrorMode(sc.ErrorMode)
+	s.svc.SetLatency(sc.Latency)
+	s.svc.SetReplySize(sc.ReplySize)
+	return nil
+}
+
+func (s *localServer) Stats(context.Context) (benchmark.ServerStats, error) {
+	return benchmark.ServerStats{Conn: s.conns.Counts(), Canceled: s.svc.CanceledCount()}, nil
+}
+
+func (s *localServer) Close() {
+	s.stop()
+	_ = s.listener.Close()
+}
+
+// remoteServer is a RouteGuideService running in a routeguide-server process
+type remoteServer struct {
+	*benchmark.ControlClient
+	cmd *exec.Cmd
+	// addr is the address the transport is served on
+	addr string
+}
+
+func (s *remoteServer) Close() {
+	_ = s.cmd.Process.Signal(os.Interrupt)
+	if err := s.cmd.Wait(); err != nil {
+		log.Printf("server process exited with: %s", err)
+	}
+}
+
+// spawnServer runs routeguide-server for the transport and waits until it is ready. Any args
+// are passed to routeguide-server in addition to those which serve the transport.
+func spawnServer(b *testing.B, transport, address string, conf *benchmark.TLSConfig,
+	args ...string) (*remoteServer, error) {
+	bin, err := serverBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	name := serverFlags[transport]
+	args = append(args, "-"+name, address)
+	if conf != nil {
+		dir := b.TempDir()
+		for _, f := range tlsFiles {
+			if err := os.WriteFile(filepath.Join(dir, f.name), (*f.pem(conf)).Bytes(), 0600); err != nil {
+				return nil, fmt.Errorf("while writing '%s': %w", f.name, err)
+			}
+		}
+		args = append(args, "-tls-dir", dir)
+		if conf.Profile != "" {
+			args = append(args, "-tls-profile", string(conf.Profile))
+		}
+	}
+	if *wire {
+		args = append(args, "-wire")
+	}
+
+	cmd := exec.Command(bin, args...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("while starting server process: %w", err)
+	}
+
+	// The server writes the address of each listener, then the admin endpoint once it is ready
+	var addr string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if a, ok := strings.CutPrefix(scanner.Text(), name+": "); ok {
+			addr = a
+		}
+		a, ok := strings.CutPrefix(scanner.Text(), adminPrefix)
+		if !ok {
+			continue
+		}
+		go func() { _, _ = io.Copy(io.Discard, stdout) }()
+		// Use a separate transport so control requests never share connections with the benchmark
+		client := &http.Client{Transport: &http.Transport{}}
+		return &remoteServer{
+			ControlClient: benchmark.NewControlClient(client, "http://"+a),
+			cmd:           cmd,
+			addr:          addr,
+		}, nil
+	}
+	_ = cmd.Wait()
+	return nil, errors.New("server process exited before it was ready")
+}
+
+var (
+	buildOnce sync.Once
+	binDir    string
+	binErr    error
+)
+
+// serverBinary returns the path to the routeguide-server binary provided by `-server-bin`, or
+// builds it the first time it is called. The binary is removed by removeServerBinary().
+func serverBinary() (string, error) {
+	if *serverBin != "" {
+		return *serverBin, nil
+	}
+	buildOnce.Do(func() {
+		if binDir, binErr = os.MkdirTemp("", "routeguide-server"); binErr != nil {
+			return
+		}
+		cmd := exec.Command("go", "build", "-o", filepath.Join(binDir, "routeguide-server"), "./cmd/routeguide-server")
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			binErr = fmt.Errorf("while building routeguide-server: %w", err)
+		}
+	})
+	return filepath.Join(binDir, "routeguide-server"), binErr
+}
+
+func removeServerBinary() {
+	if binDir != "" {
+		_ = os.RemoveAll(binDir)
+	}
+}
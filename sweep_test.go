@@ -0,0 +1,278 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	sweep = flag.Bool("sweep", false, "run BenchmarkSweep, which benchmarks every combination of the "+
+		"-sweep-* settings for each transport")
+	sweepConns = flag.String("sweep-conns", "1,4,16,64", "comma separated connection counts; http.Transport "+
+		"MaxConnsPerHost for HTTP/1 (0 is unlimited), and the number of clients for HTTP/2 and gRPC")
+	sweepIdle = flag.String("sweep-idle", "2,16,64", "comma separated http.Transport MaxIdleConnsPerHost "+
+		"values for HTTP/1")
+	sweepStreams = flag.String("sweep-streams", "16,100,250,1000", "comma separated server "+
+		"MaxConcurrentStreams values for HTTP/2 and gRPC")
+	sweepParallelism = flag.Int("sweep-parallelism", 64, "concurrent callers per GOMAXPROCS in BenchmarkSweep")
+)
+
+// sweepConfig is one combination of client and server settings for a transport
+type sweepConfig struct {
+	name  string
+	serve func(l net.Listener, svc *server.RouteGuideService) (stop func())
+	dial  func(addr string) (getFeature func(context.Context, *pb.Point) error, closer func(), err error)
+}
+
+// sweepSettings are the values each setting is swept across
+type sweepSettings struct {
+	conns, idle, streams []int
+}
+
+// sweeps return the configurations swept for each transport
+var sweeps = map[string]func(s sweepSettings) []sweepConfig{
+	"GRPC":  sweepGRPC,
+	"HTTP2": sweepH2C,
+	"HTTP1": sweepHTTP1,
+}
+
+// BenchmarkSweep calls each transport from many goroutines with every combination of its connection
+// pool and stream limit settings, and logs the fastest combination for each transport.
+func BenchmarkSweep(b *testing.B) {
+	if !*sweep {
+		b.Skip("pass -sweep to sweep the connection pool and stream limit settings of each transport")
+	}
+	var s sweepSettings
+	for _, f := range []struct {
+		name  string
+		value string
+		to    *[]int
+	}{
+		{name: "sweep-conns", value: *sweepConns, to: &s.conns},
+		{name: "sweep-idle", value: *sweepIdle, to: &s.idle},
+		{name: "sweep-streams", value: *sweepStreams, to: &s.streams},
+	} {
+		v, err := parseInts(f.name, f.value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		*f.to = v
+	}
+
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTP1"} {
+		b.Run(transport, func(b *testing.B) {
+			// The testing package runs each configuration several times while it grows b.N, the
+			// last and longest run is the one reported. Configurations which failed, or were not
+			// selected by -bench, are left out.
+			nsPerOp := make(map[string]float64)
+			var completed []string
+			for _, c := range sweeps[transport](s) {
+				b.Run(c.name, func(b *testing.B) {
+					ns := runSweep(b, c)
+					if b.Failed() {
+						return
+					}
+					if _, ok := nsPerOp[c.name]; !ok {
+						completed = append(completed, c.name)
+					}
+					nsPerOp[c.name] = ns
+				})
+			}
+			if len(completed) == 0 {
+				return
+			}
+
+			sort.SliceStable(completed, func(i, j int) bool {
+				return nsPerOp[completed[i]] < nsPerOp[completed[j]]
+			})
+			b.Logf("best %s configuration: %s (%.0f ns/op)", transport, completed[0], nsPerOp[completed[0]])
+		})
+	}
+}
+
+// runSweep calls the server from many goroutines using the configuration and returns the ns/op
+func runSweep(b *testing.B, c sweepConfig) float64 {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.serve(l, server.NewRouteGuideServer())()
+
+	getFeature, closer, err := c.dial(l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer closer()
+
+	ctx := context.Background()
+	if err := getFeature(ctx, knownPoint); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetParallelism(*sweepParallelism)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(p *testing.PB) {
+		for p.Next() {
+			if err := getFeature(ctx, knownPoint); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	return float64(b.Elapsed().Nanoseconds()) / float64(b.N)
+}
+
+func sweepGRPC(s sweepSettings) []sweepConfig {
+	var configs []sweepConfig
+	for _, streams := range s.streams {
+		for _, conns := range s.conns {
+			streams, conns := streams, max(conns, 1)
+			configs = append(configs, sweepConfig{
+				name: fmt.Sprintf("Streams=%d,Conns=%d", streams, conns),
+				serve: func(l net.Listener, svc *server.RouteGuideService) func() {
+					srv := grpc.NewServer(grpc.MaxConcurrentStreams(uint32(streams)))
+					pb.RegisterRouteGuideServer(srv, svc)
+					go func() { _ = srv.Serve(l) }()
+					return srv.Stop
+				},
+				dial: func(addr string) (func(context.Context, *pb.Point) error, func(), error) {
+					clients := make([]pb.RouteGuideClient, conns)
+					closers := make([]func(), 0, conns)
+					closeAll := func() {
+						for _, c := range closers {
+							c()
+						}
+					}
+					for i := range clients {
+						conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+							grpc.WithBlock())
+						if err != nil {
+							closeAll()
+							return nil, nil, err
+						}
+						closers = append(closers, func() { _ = conn.Close() })
+						clients[i] = pb.NewRouteGuideClient(conn)
+					}
+					var next atomic.Uint64
+					return func(ctx context.Context, point *pb.Point) error {
+						_, err := clients[next.Add(1)%uint64(conns)].GetFeature(ctx, point)
+						return err
+					}, closeAll, nil
+				},
+			})
+		}
+	}
+	return configs
+}
+
+func sweepH2C(s sweepSettings) []sweepConfig {
+	var configs []sweepConfig
+	for _, streams := range s.streams {
+		for _, conns := range s.conns {
+			streams, conns := streams, max(conns, 1)
+			configs = append(configs, sweepConfig{
+				name: fmt.Sprintf("Streams=%d,Conns=%d", streams, conns),
+				serve: func(l net.Listener, svc *server.RouteGuideService) func() {
+					h2s := &http2.Server{MaxConcurrentStreams: uint32(streams)}
+					srv := &http.Server{Handler: h2c.NewHandler(benchmark.NewHTTPHandler(svc), h2s)}
+					go func() {
+						if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+							panic(err)
+						}
+					}()
+					return func() { _ = srv.Close() }
+				},
+				dial: func(addr string) (func(context.Context, *pb.Point) error, func(), error) {
+					// Each http2.Transport holds its own connection, unless the stream limit forces
+					// it to open another
+					clients := make([]*benchmark.HTTPClient, conns)
+					transports := make([]*http2.Transport, conns)
+					for i := range clients {
+						transports[i] = &http2.Transport{
+							AllowHTTP: true,
+							DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+								var d net.Dialer
+								return d.DialContext(ctx, network, addr)
+							},
+						}
+						clients[i] = benchmark.NewClient(&http.Client{Transport: transports[i]}, "http://"+addr)
+					}
+					closeAll := func() {
+						for _, t := range transports {
+							t.CloseIdleConnections()
+						}
+					}
+					var next atomic.Uint64
+					return func(ctx context.Context, point *pb.Point) error {
+						var resp pb.Feature
+						return clients[next.Add(1)%uint64(conns)].GetFeature(ctx, point, &resp)
+					}, closeAll, nil
+				},
+			})
+		}
+	}
+	return configs
+}
+
+func sweepHTTP1(s sweepSettings) []sweepConfig {
+	var configs []sweepConfig
+	for _, idle := range s.idle {
+		for _, conns := range s.conns {
+			idle, conns := idle, conns
+			configs = append(configs, sweepConfig{
+				name: fmt.Sprintf("MaxIdleConnsPerHost=%d,MaxConnsPerHost=%d", idle, conns),
+				serve: func(l net.Listener, svc *server.RouteGuideService) func() {
+					return serveHTTP1(l, svc, nil)
+				},
+				dial: func(addr string) (func(context.Context, *pb.Point) error, func(), error) {
+					transport := http.DefaultTransport.(*http.Transport).Clone()
+					transport.MaxIdleConnsPerHost = idle
+					transport.MaxConnsPerHost = conns
+					client := benchmark.NewClient(&http.Client{Transport: transport}, "http://"+addr)
+					return func(ctx context.Context, point *pb.Point) error {
+						var resp pb.Feature
+						return client.GetFeature(ctx, point, &resp)
+					}, transport.CloseIdleConnections, nil
+				},
+			})
+		}
+	}
+	return configs
+}
+
+// parseInts parses the comma separated integers of the named flag
+func parseInts(name, value string) ([]int, error) {
+	var ints []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s '%s': %w", name, value, err)
+		}
+		if n < 0 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid -%s '%s': %d is out of range", name, value, n)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
@@ -2,9 +2,12 @@ package benchmark
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -14,16 +17,113 @@ import (
 	"log"
 	"math/big"
 	"net"
+	"os"
+	"slices"
 	"strings"
 	"time"
 )
 
 const (
-	blockTypeEC   = "EC PRIVATE KEY"
-	blockTypeCert = "CERTIFICATE"
+	blockTypeEC    = "EC PRIVATE KEY"
+	blockTypePKCS8 = "PRIVATE KEY"
+	blockTypeCert  = "CERTIFICATE"
 )
 
+// KeyType is the algorithm of the keys generated by SetupTLS()
+type KeyType string
+
+const (
+	KeyTypeP256    KeyType = "P-256"
+	KeyTypeP384    KeyType = "P-384"
+	KeyTypeP521    KeyType = "P-521"
+	KeyTypeEd25519 KeyType = "Ed25519"
+	KeyTypeRSA2048 KeyType = "RSA-2048"
+	KeyTypeRSA4096 KeyType = "RSA-4096"
+)
+
+// KeyTypes are the values accepted by TLSConfig.KeyType
+var KeyTypes = []KeyType{KeyTypeP256, KeyTypeP384, KeyTypeP521, KeyTypeEd25519, KeyTypeRSA2048, KeyTypeRSA4096}
+
+// TLSProfile selects the TLS versions and cipher suites negotiated by the configs SetupTLS() creates
+type TLSProfile string
+
+const (
+	// TLSProfileDefault uses the crypto/tls defaults, which negotiate TLS 1.3
+	TLSProfileDefault TLSProfile = "Default"
+	// TLSProfileTLS13 only accepts TLS 1.3. The TLS 1.3 cipher suites cannot be configured, crypto/tls
+	// prefers AES-GCM when the CPU has AES instructions and ChaCha20-Poly1305 when it does not.
+	TLSProfileTLS13 TLSProfile = "TLS1.3"
+	// TLSProfileTLS12AES128GCM, TLSProfileTLS12AES256GCM and TLSProfileTLS12ChaCha20 only
+	// accept TLS 1.2 with ECDHE and the named cipher
+	TLSProfileTLS12AES128GCM TLSProfile = "TLS1.2-AES128-GCM"
+	TLSProfileTLS12AES256GCM TLSProfile = "TLS1.2-AES256-GCM"
+	TLSProfileTLS12ChaCha20  TLSProfile = "TLS1.2-ChaCha20"
+)
+
+// TLSProfiles are the values accepted by TLSConfig.Profile
+var TLSProfiles = []TLSProfile{TLSProfileDefault, TLSProfileTLS13, TLSProfileTLS12AES128GCM,
+	TLSProfileTLS12AES256GCM, TLSProfileTLS12ChaCha20}
+
+// http2CipherSuites are also accepted by servers of the TLS 1.2 profiles, as HTTP/2 servers refuse
+// cipher suite lists without AES-128-GCM. Clients only offer the cipher of the profile, so it is
+// still the one negotiated.
+var http2CipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+}
+
+// tlsProfiles are the versions and cipher suites of each profile. The TLS 1.2 profiles include
+// the ECDSA and the RSA variant of their cipher, so they work with every KeyType.
+var tlsProfiles = map[TLSProfile]struct {
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+}{
+	"":                {},
+	TLSProfileDefault: {},
+	TLSProfileTLS13:   {minVersion: tls.VersionTLS13},
+	TLSProfileTLS12AES128GCM: {
+		minVersion: tls.VersionTLS12,
+		maxVersion: tls.VersionTLS12,
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+	},
+	TLSProfileTLS12AES256GCM: {
+		minVersion: tls.VersionTLS12,
+		maxVersion: tls.VersionTLS12,
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		},
+	},
+	TLSProfileTLS12ChaCha20: {
+		minVersion: tls.VersionTLS12,
+		maxVersion: tls.VersionTLS12,
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	},
+}
+
 type TLSConfig struct {
+	// (Optional) The path to the Trusted Certificate Authority in PEM format. It may hold any
+	// intermediate certificates as well as the root.
+	CaFile string
+
+	// (Optional) The path to the CA private key in PEM format. It is only used to sign a generated
+	// certificate when KeyFile and CertFile are unset.
+	CaKeyFile string
+
+	// (Optional) The path to the private key in PEM format
+	KeyFile string
+
+	// (Optional) The path to the certificate in PEM format. Any intermediate certificates should
+	// follow the certificate, so they are sent to the peer with it.
+	CertFile string
+
 	// (Optional) Sets the Client Authentication type as defined in the 'tls' package.
 	// Defaults to tls.NoClientCert.See the standard library tls.ClientAuthType for valid values.
 	// If set to anything but tls.NoClientCert then SetupTLS() attempts to load ClientAuthCaFile,
@@ -39,8 +139,8 @@ type TLSConfig struct {
 	// ClientAuth is set and this field is empty then KeyFile is used to create the ClientTLS.
 	ClientAuthKeyFile string
 
-	// (Optional) The path to the client cert key, which is used to create the ClientTLS config. If
-	// ClientAuth is set and this field is empty then KeyFile is used to create the ClientTLS.
+	// (Optional) The path to the client cert, which is used to create the ClientTLS config. If
+	// ClientAuth is set and this field is empty then CertFile is used to create the ClientTLS.
 	ClientAuthCertFile string
 
 	// (Optional) If InsecureSkipVerify is true, TLS clients will accept any certificate
@@ -71,6 +171,22 @@ type TLSConfig struct {
 	// (Optional) the server name to check when validating the provided certificate
 	ClientAuthServerName string
 
+	// (Optional) The TLS versions and cipher suites of the ServerTLS and ClientTLS configs.
+	// Defaults to TLSProfileDefault.
+	Profile TLSProfile
+
+	// (Optional) The algorithm of the CA and certificate keys generated when none are provided.
+	// Defaults to KeyTypeP256.
+	KeyType KeyType
+
+	// (Optional) If set, CertFile and KeyFile are checked for a new key pair at most once per interval
+	// during handshakes, so the certificate can be rotated without a restart. The ServerTLS and
+	// ClientTLS configs then use GetCertificate and GetClientCertificate instead of Certificates.
+	CertReloadInterval time.Duration
+
+	// The reloader of CertFile and KeyFile created by SetupTLS() when CertReloadInterval is set
+	CertReloader *CertReloader
+
 	// (Optional) The config created for use by the gubernator server. If set, all other
 	// fields in this struct are ignored and this config is used. If unset, gubernator.SetupTLS()
 	// will create a config using the above fields.
@@ -87,46 +203,43 @@ func SetupTLS(conf *TLSConfig) error {
 		return nil
 	}
 
-	// Basic config with reasonably secure defaults
-	//setter.SetDefault(&conf.ServerTLS, &tls.Config{
-	//	CipherSuites: []uint16{
-	//		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-	//		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-	//		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-	//		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-	//		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-	//		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-	//		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-	//		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-	//		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-	//		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-	//		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-	//		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-	//		tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-	//		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-	//		tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
-	//		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-	//		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-	//	},
-	//	ClientAuth: conf.ClientAuth,
-	//	MinVersion: tls.VersionTLS13,
-	//	NextProtos: []string{
-	//		"h2", "http/1.1", // enable HTTP/2
-	//	},
-	//})
-	//setter.SetDefault(&conf.ClientTLS, &tls.Config{})
-
 	conf.ServerTLS = &tls.Config{}
 	conf.ClientTLS = &tls.Config{}
 
-	// Generate CA Cert and Private Key
-	if err := selfCA(conf); err != nil {
-		return fmt.Errorf("while generating self signed CA certs: %w", err)
+	if conf.KeyType != "" && !slices.Contains(KeyTypes, conf.KeyType) {
+		return fmt.Errorf("unknown key type '%s'; expected one of %v", conf.KeyType, KeyTypes)
 	}
 
-	// Generate Server Cert and Private Key
-	if err := selfCert(conf); err != nil {
-		return fmt.Errorf("while generating self signed server certs: %w", err)
+	profile, ok := tlsProfiles[conf.Profile]
+	if !ok {
+		return fmt.Errorf("unknown TLS profile '%s'; expected one of %v", conf.Profile, TLSProfiles)
+	}
+	for _, c := range []*tls.Config{conf.ServerTLS, conf.ClientTLS} {
+		c.MinVersion, c.MaxVersion, c.CipherSuites = profile.minVersion, profile.maxVersion, profile.cipherSuites
+	}
+	if profile.cipherSuites != nil {
+		conf.ServerTLS.CipherSuites = append(slices.Clone(profile.cipherSuites), http2CipherSuites...)
+	}
+
+	if err := loadFiles(conf); err != nil {
+		return err
+	}
+
+	// Certificates are only generated when none were provided. A provided CA and CA key sign the
+	// generated certificate, otherwise a self signed CA is generated first.
+	if conf.CertPEM == nil && conf.KeyPEM == nil {
+		// Generate CA Cert and Private Key
+		if err := selfCA(conf); err != nil {
+			return fmt.Errorf("while generating self signed CA certs: %w", err)
+		}
+
+		// Generate Server Cert and Private Key
+		if err := selfCert(conf); err != nil {
+			return fmt.Errorf("while generating self signed server certs: %w", err)
+		}
+	}
+	if conf.CertPEM == nil || conf.KeyPEM == nil {
+		return errors.New("both a certificate and its private key are required")
 	}
 
 	if conf.CaPEM != nil {
@@ -167,6 +280,7 @@ func SetupTLS(conf *TLSConfig) error {
 		}
 
 		conf.ServerTLS.ClientCAs = clientPool
+		conf.ServerTLS.ClientAuth = conf.ClientAuth
 
 		// If client auth key/cert was provided
 		if conf.ClientAuthKeyPEM != nil && conf.ClientAuthCertPEM != nil {
@@ -178,11 +292,55 @@ func SetupTLS(conf *TLSConfig) error {
 		}
 	}
 
+	if conf.CertReloadInterval != 0 {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return errors.New("CertReloadInterval requires CertFile and KeyFile")
+		}
+		r, err := NewCertReloader(conf.CertFile, conf.KeyFile, conf.CertReloadInterval)
+		if err != nil {
+			return err
+		}
+		conf.CertReloader = r
+		conf.ServerTLS.Certificates = nil
+		conf.ServerTLS.GetCertificate = r.GetCertificate
+		// Clients only present the reloaded certificate when no client auth certificate was provided
+		if conf.ClientAuth == tls.NoClientCert || conf.ClientAuthKeyPEM == nil || conf.ClientAuthCertPEM == nil {
+			conf.ClientTLS.Certificates = nil
+			conf.ClientTLS.GetClientCertificate = r.GetClientCertificate
+		}
+	}
+
 	conf.ClientTLS.ServerName = conf.ClientAuthServerName
 	conf.ClientTLS.InsecureSkipVerify = conf.InsecureSkipVerify
 	return nil
 }
 
+// loadFiles reads each file which is set into its PEM field, replacing any PEM already provided
+func loadFiles(conf *TLSConfig) error {
+	for _, f := range []struct {
+		path string
+		pem  **bytes.Buffer
+	}{
+		{path: conf.CaFile, pem: &conf.CaPEM},
+		{path: conf.CaKeyFile, pem: &conf.CaKeyPEM},
+		{path: conf.KeyFile, pem: &conf.KeyPEM},
+		{path: conf.CertFile, pem: &conf.CertPEM},
+		{path: conf.ClientAuthCaFile, pem: &conf.ClientAuthCaPEM},
+		{path: conf.ClientAuthKeyFile, pem: &conf.ClientAuthKeyPEM},
+		{path: conf.ClientAuthCertFile, pem: &conf.ClientAuthCertPEM},
+	} {
+		if f.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("while reading '%s': %w", f.path, err)
+		}
+		*f.pem = bytes.NewBuffer(b)
+	}
+	return nil
+}
+
 func selfCert(conf *TLSConfig) error {
 	if conf.CertPEM != nil && conf.KeyPEM != nil {
 		return nil
@@ -214,19 +372,19 @@ func selfCert(conf *TLSConfig) error {
 	}())
 
 	// Generate a public / private key
-	privKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	privKey, keyBlock, err := generateKey(conf.KeyType)
 	if err != nil {
 		return fmt.Errorf("while generating pubic/private key pair: %w", err)
 	}
 
 	// Attempt to sign the generated certs with the provided CaFile
-	if conf.CaPEM == nil && conf.CaKeyPEM == nil {
-		return errors.New("unable to generate server certs without a signing CA")
+	if conf.CaPEM == nil || conf.CaKeyPEM == nil {
+		return errors.New("unable to generate server certs without a signing CA and its private key")
 	}
 
 	keyPair, err := tls.X509KeyPair(conf.CaPEM.Bytes(), conf.CaKeyPEM.Bytes())
 	if err != nil {
-		return fmt.Errorf("while reading generated PEMs")
+		return fmt.Errorf("while parsing CA certificate and private key: %w", err)
 	}
 
 	if len(keyPair.Certificate) == 0 {
@@ -235,33 +393,40 @@ func selfCert(conf *TLSConfig) error {
 
 	caCert, err := x509.ParseCertificate(keyPair.Certificate[0])
 	if err != nil {
-		return fmt.Errorf("while parsing CA Cert")
+		return fmt.Errorf("while parsing CA Cert: %w", err)
 	}
 
-	signedBytes, err := x509.CreateCertificate(rand.Reader, &cert, caCert, &privKey.PublicKey, keyPair.PrivateKey)
+	signedBytes, err := x509.CreateCertificate(rand.Reader, &cert, caCert, privKey.Public(), keyPair.PrivateKey)
 	if err != nil {
 		return fmt.Errorf("while self signing server cert: %w", err)
 	}
 
-	conf.CertPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.CertPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: signedBytes,
-	}); err != nil {
-		return fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
+	// When the CA is an intermediate, the intermediates follow the certificate so peers which only
+	// trust the root can verify it. The root is never sent.
+	chain := [][]byte{signedBytes}
+	for _, der := range keyPair.Certificate {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("while parsing CA Cert: %w", err)
+		}
+		if !bytes.Equal(c.RawIssuer, c.RawSubject) {
+			chain = append(chain, der)
+		}
 	}
 
-	b, err := x509.MarshalECPrivateKey(privKey)
-	if err != nil {
-		return fmt.Errorf("while encoding EC Marshalling: %w", err)
+	conf.CertPEM = new(bytes.Buffer)
+	for _, der := range chain {
+		if err := pem.Encode(conf.CertPEM, &pem.Block{
+			Type:  blockTypeCert,
+			Bytes: der,
+		}); err != nil {
+			return fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
+		}
 	}
 
 	conf.KeyPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.KeyPEM, &pem.Block{
-		Type:  blockTypeEC,
-		Bytes: b,
-	}); err != nil {
-		return fmt.Errorf("while encoding EC KEY PEM: %w", err)
+	if err := pem.Encode(conf.KeyPEM, keyBlock); err != nil {
+		return fmt.Errorf("while encoding KEY PEM: %w", err)
 	}
 	return nil
 }
@@ -278,21 +443,18 @@ func selfCA(conf *TLSConfig) error {
 		IsCA:                  true,
 	}
 
-	var privKey *ecdsa.PrivateKey
-	var err error
-	var b []byte
-
-	if conf.CaPEM != nil && conf.CaKeyPEM != nil {
+	// A CA without its private key cannot sign, which selfCert() reports
+	if conf.CaPEM != nil || conf.CaKeyPEM != nil {
 		return nil
 	}
 
 	log.Print("Generating CA Certificates....")
-	privKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	privKey, keyBlock, err := generateKey(conf.KeyType)
 	if err != nil {
 		return fmt.Errorf("while generating pubic/private key pair: %w", err)
 	}
 
-	b, err = x509.CreateCertificate(rand.Reader, &ca, &ca, &privKey.PublicKey, privKey)
+	b, err := x509.CreateCertificate(rand.Reader, &ca, &ca, privKey.Public(), privKey)
 	if err != nil {
 		return fmt.Errorf("while self signing CA certificate: %w", err)
 	}
@@ -305,17 +467,48 @@ func selfCA(conf *TLSConfig) error {
 		return fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
 	}
 
-	b, err = x509.MarshalECPrivateKey(privKey)
+	conf.CaKeyPEM = new(bytes.Buffer)
+	if err := pem.Encode(conf.CaKeyPEM, keyBlock); err != nil {
+		return fmt.Errorf("while encoding private key into PEM: %w", err)
+	}
+	return nil
+}
+
+// generateKey returns a new private key of the type, and its PEM block. EC keys are encoded as
+// SEC 1, other keys as PKCS #8.
+func generateKey(t KeyType) (crypto.Signer, *pem.Block, error) {
+	var key crypto.Signer
+	var err error
+	switch t {
+	case KeyTypeP256, "":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeP384:
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyTypeP521:
+		key, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeRSA2048:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA4096:
+		key, err = rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return nil, nil, fmt.Errorf("unknown key type '%s'", t)
+	}
 	if err != nil {
-		return fmt.Errorf("while marshalling EC private key: %w", err)
+		return nil, nil, err
 	}
 
-	conf.CaKeyPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.CaKeyPEM, &pem.Block{
-		Type:  blockTypeEC,
-		Bytes: b,
-	}); err != nil {
-		return fmt.Errorf("while encoding EC private key into PEM: %w", err)
+	if ec, ok := key.(*ecdsa.PrivateKey); ok {
+		b, err := x509.MarshalECPrivateKey(ec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("while marshalling EC private key: %w", err)
+		}
+		return key, &pem.Block{Type: blockTypeEC, Bytes: b}, nil
 	}
-	return nil
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while marshalling private key: %w", err)
+	}
+	return key, &pem.Block{Type: blockTypePKCS8, Bytes: b}, nil
 }
@@ -0,0 +1,95 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertReloader provides the key pair in a certificate and key file to tls.Config.GetCertificate and
+// tls.Config.GetClientCertificate, and loads a new key pair when the files change. The files are
+// checked during handshakes, at most once per interval, so there is nothing to stop.
+//
+// Connections which completed their handshake keep the certificate they were established with. The
+// files should be replaced by renaming new files over them, and a new key pair should be written
+// at least one modification time tick after the previous one.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	reloads  atomic.Int64
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewCertReloader loads the key pair in the files, which are then checked for changes at most once
+// per interval
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	r.reloads.Store(0)
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// Reloads returns the number of times a new key pair was loaded after the first
+func (r *CertReloader) Reloads() int64 {
+	return r.reloads.Load()
+}
+
+func (r *CertReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := time.Now(); now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		// A certificate which does not match its key is usually one whose key has not been
+		// replaced yet, so the previous key pair is used until the next check
+		if err := r.reload(); err != nil {
+			log.Printf("while reloading certificate; using the previous certificate: %s", err)
+		}
+	}
+	return r.cert
+}
+
+// reload loads the key pair if either file was modified since it was last loaded
+func (r *CertReloader) reload() error {
+	var modTime time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("while reading '%s': %w", name, err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if r.cert != nil && modTime.Equal(r.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("while loading key pair '%s' and '%s': %w", r.certFile, r.keyFile, err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	r.reloads.Add(1)
+	return nil
+}
@@ -0,0 +1,417 @@
+package benchmark_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var tlsProfileReply = flag.Int("tls-profile-reply", 64<<10, "size in bytes of the large replies in "+
+	"BenchmarkTLSProfile")
+
+func TestSetupTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, b ...[]byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, bytes.Join(b, nil), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A root CA which signs an intermediate, as a real PKI chain would
+	var root benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&root); err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey := newIntermediate(t, root.CaPEM.Bytes(), root.CaKeyPEM.Bytes())
+	rootFile := write("root.pem", root.CaPEM.Bytes())
+	chainFile := write("chain.pem", intermediate, root.CaPEM.Bytes())
+	intermediateKeyFile := write("intermediate.key", intermediateKey)
+
+	// The intermediate and its key sign a generated certificate
+	signed := benchmark.TLSConfig{CaFile: chainFile, CaKeyFile: intermediateKeyFile}
+	if err := benchmark.SetupTLS(&signed); err != nil {
+		t.Fatal(err)
+	}
+	certFile := write("cert.pem", signed.CertPEM.Bytes())
+	keyFile := write("cert.key", signed.KeyPEM.Bytes())
+	if n := len(signed.ServerTLS.Certificates[0].Certificate); n != 2 {
+		t.Errorf("expected the certificate to be followed by the intermediate; got '%d' certificates", n)
+	}
+
+	// Clients which only trust the root must verify the chain
+	rootOnly := benchmark.TLSConfig{CaFile: rootFile, CertFile: certFile, KeyFile: keyFile}
+	if err := benchmark.SetupTLS(&rootOnly); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(signed.ServerTLS, rootOnly.ClientTLS); err != nil {
+		t.Errorf("expected a client trusting the root to verify the chain; got '%v'", err)
+	}
+
+	t.Run("CertFiles", func(t *testing.T) {
+		// Nothing is generated when the certificate and key are provided, the CA key is not needed
+		conf := benchmark.TLSConfig{CaFile: rootFile, CertFile: certFile, KeyFile: keyFile}
+		if err := benchmark.SetupTLS(&conf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(conf.CertPEM.Bytes(), signed.CertPEM.Bytes()) {
+			t.Error("expected the certificate to be loaded from CertFile")
+		}
+		if conf.CaKeyPEM != nil {
+			t.Error("expected no CA to be generated")
+		}
+		if err := handshake(conf.ServerTLS, rootOnly.ClientTLS); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("ClientAuthFiles", func(t *testing.T) {
+		conf := benchmark.TLSConfig{
+			ClientAuth:         tls.RequireAndVerifyClientCert,
+			CaFile:             rootFile,
+			CertFile:           certFile,
+			KeyFile:            keyFile,
+			ClientAuthCaFile:   rootFile,
+			ClientAuthCertFile: certFile,
+			ClientAuthKeyFile:  keyFile,
+		}
+		if err := benchmark.SetupTLS(&conf); err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(conf.ServerTLS, conf.ClientTLS); err != nil {
+			t.Error(err)
+		}
+		noCert := conf.ClientTLS.Clone()
+		noCert.Certificates = nil
+		if err := handshake(conf.ServerTLS, noCert); err == nil {
+			t.Error("expected the server to reject a client without a certificate")
+		}
+	})
+
+	for _, test := range []struct {
+		name string
+		conf benchmark.TLSConfig
+	}{
+		{name: "MissingFile", conf: benchmark.TLSConfig{CaFile: filepath.Join(dir, "missing.pem")}},
+		{name: "CertWithoutKey", conf: benchmark.TLSConfig{CaFile: rootFile, CertFile: certFile}},
+		{name: "CAWithoutKey", conf: benchmark.TLSConfig{CaFile: rootFile}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := benchmark.SetupTLS(&test.conf); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// newIntermediate returns the PEM of an intermediate CA signed by the CA, and its private key
+func newIntermediate(t *testing.T, caPEM, caKeyPEM []byte) ([]byte, []byte) {
+	t.Helper()
+	ca, err := tls.X509KeyPair(caPEM, caKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(2320),
+		Subject:               pkix.Name{Organization: []string{"intermediate"}},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
+}
+
+// handshake completes a TLS handshake between the server and client configs
+func handshake(server, client *tls.Config) error {
+	sc, cc := net.Pipe()
+	defer func() { _ = sc.Close() }()
+	defer func() { _ = cc.Close() }()
+
+	client = client.Clone()
+	client.ServerName = "localhost"
+	errs := make(chan error, 1)
+	go func() {
+		s := tls.Server(sc, server)
+		errs <- s.Handshake()
+		// Read until the client closes, so the client receives the server's verdict on its certificate
+		_, _ = s.Read(make([]byte, 1))
+	}()
+	c := tls.Client(cc, client)
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+	// With TLS 1.3 the client learns the server rejected its certificate on the first read
+	_ = c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := c.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return err
+		}
+	}
+	_ = c.Close()
+	return <-errs
+}
+
+func TestSetupTLSKeyTypes(t *testing.T) {
+	for _, keyType := range benchmark.KeyTypes {
+		t.Run(string(keyType), func(t *testing.T) {
+			if testing.Short() && keyType == benchmark.KeyTypeRSA4096 {
+				t.Skip("generating RSA-4096 keys is slow")
+			}
+			conf := benchmark.TLSConfig{KeyType: keyType}
+			if err := benchmark.SetupTLS(&conf); err != nil {
+				t.Fatal(err)
+			}
+			if err := handshake(conf.ServerTLS, conf.ClientTLS); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	conf := benchmark.TLSConfig{KeyType: "DSA"}
+	if err := benchmark.SetupTLS(&conf); err == nil {
+		t.Error("expected an error for an unknown key type")
+	}
+}
+
+// BenchmarkHandshake measures the CPU cost of a TLS 1.3 handshake, including both the client and
+// the server, with certificates generated for each key type. Full handshakes verify the
+// certificate chain, Resumed handshakes use a session ticket from a previous connection.
+func BenchmarkHandshake(b *testing.B) {
+	for _, keyType := range benchmark.KeyTypes {
+		conf := benchmark.TLSConfig{KeyType: keyType}
+		if err := benchmark.SetupTLS(&conf); err != nil {
+			b.Fatal(err)
+		}
+		client := conf.ClientTLS.Clone()
+		client.ServerName = "localhost"
+
+		b.Run(string(keyType)+"/Full", func(b *testing.B) {
+			runHandshakes(b, conf.ServerTLS, client, false)
+		})
+		b.Run(string(keyType)+"/Resumed", func(b *testing.B) {
+			resumed := client.Clone()
+			resumed.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+			runHandshakes(b, conf.ServerTLS, resumed, true)
+		})
+	}
+}
+
+func TestTLSProfiles(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	for _, test := range []struct {
+		profile benchmark.TLSProfile
+		version uint16
+		// cipher is part of the name of the negotiated cipher suite
+		cipher string
+	}{
+		{profile: benchmark.TLSProfileDefault, version: tls.VersionTLS13},
+		{profile: benchmark.TLSProfileTLS13, version: tls.VersionTLS13},
+		{profile: benchmark.TLSProfileTLS12AES128GCM, version: tls.VersionTLS12, cipher: "AES_128_GCM"},
+		{profile: benchmark.TLSProfileTLS12AES256GCM, version: tls.VersionTLS12, cipher: "AES_256_GCM"},
+		{profile: benchmark.TLSProfileTLS12ChaCha20, version: tls.VersionTLS12, cipher: "CHACHA20_POLY1305"},
+	} {
+		// The TLS 1.2 cipher suites depend on the type of the certificate key
+		for _, keyType := range []benchmark.KeyType{benchmark.KeyTypeP256, benchmark.KeyTypeEd25519,
+			benchmark.KeyTypeRSA2048} {
+			t.Run(string(test.profile)+"/"+string(keyType), func(t *testing.T) {
+				conf := benchmark.TLSConfig{Profile: test.profile, KeyType: keyType}
+				if err := benchmark.SetupTLS(&conf); err != nil {
+					t.Fatal(err)
+				}
+				client := conf.ClientTLS.Clone()
+				client.ServerName = "localhost"
+				state, err := handshakeTicket(l, conf.ServerTLS, client)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if state.Version != test.version {
+					t.Errorf("expected version '%s'; got '%s'", tls.VersionName(test.version),
+						tls.VersionName(state.Version))
+				}
+				if name := tls.CipherSuiteName(state.CipherSuite); !strings.Contains(name, test.cipher) {
+					t.Errorf("expected a %s cipher suite; got '%s'", test.cipher, name)
+				}
+			})
+		}
+	}
+
+	conf := benchmark.TLSConfig{Profile: "SSL3"}
+	if err := benchmark.SetupTLS(&conf); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+// BenchmarkTLSProfile calls HTTPS and gRPC with TLS for each TLS profile, with the keys chosen by
+// -key-type. Connections are established before the timer starts, so the difference between the
+// profiles is the cost of the record layer. The large replies make the cipher a larger share of
+// each call.
+func BenchmarkTLSProfile(b *testing.B) {
+	for _, profile := range benchmark.TLSProfiles {
+		conf := benchmark.TLSConfig{Profile: profile, KeyType: benchmark.KeyType(*keyType)}
+		if err := benchmark.SetupTLS(&conf); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, transport := range []string{"HTTPS", "GRPCTLS"} {
+			b.Run(string(profile)+"/"+transport, func(b *testing.B) {
+				l, err := net.Listen("tcp", "localhost:0")
+				if err != nil {
+					b.Fatal(err)
+				}
+				svc := server.NewRouteGuideServer()
+				defer servers[transport](l, svc, &conf)()
+				getFeature := dialTLSProfile(b, transport, l.Addr().String(), &conf)
+
+				for _, size := range []struct {
+					name  string
+					reply int
+				}{
+					{name: "GetFeature"},
+					{name: "LargeReply", reply: *tlsProfileReply},
+				} {
+					b.Run(size.name, func(b *testing.B) {
+						svc.SetReplySize(size.reply)
+						defer svc.SetReplySize(0)
+						runCodecTransport(b, getFeature)
+						if size.reply != 0 {
+							b.SetBytes(int64(size.reply))
+						}
+					})
+				}
+			})
+		}
+	}
+}
+
+// dialTLSProfile returns a GetFeature func of a client of the transport, which uses the client TLS
+// config. The connection is closed when the benchmark is done.
+func dialTLSProfile(b *testing.B, transport, addr string, conf *benchmark.TLSConfig) func(context.Context) error {
+	if transport == "GRPCTLS" {
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(conf.ClientTLS)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { _ = conn.Close() })
+		client := pb.NewRouteGuideClient(conn)
+		return func(ctx context.Context) error {
+			_, err := client.GetFeature(ctx, knownPoint)
+			return err
+		}
+	}
+
+	hc := &http.Client{Transport: &http2.Transport{TLSClientConfig: conf.ClientTLS}}
+	b.Cleanup(hc.CloseIdleConnections)
+	client := benchmark.NewClient(hc, "https://"+addr, benchmark.WithPooling())
+	return func(ctx context.Context) error {
+		var resp pb.Feature
+		return client.GetFeature(ctx, knownPoint, &resp)
+	}
+}
+
+func runHandshakes(b *testing.B, server, client *tls.Config, resume bool) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	// The first handshake stores the session ticket used by the resumed handshakes
+	if _, err := handshakeTicket(l, server, client); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		state, err := handshakeTicket(l, server, client)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if state.DidResume != resume {
+			b.Fatalf("expected resumed handshake '%t'; got '%t'", resume, state.DidResume)
+		}
+	}
+}
+
+// handshakeTicket completes a handshake over a connection to the listener, and waits for the
+// session ticket the server sends after it. It returns the state of the client connection. Unlike
+// net.Pipe(), TCP buffers the ticket while the client writes its Finished message.
+func handshakeTicket(l net.Listener, server, client *tls.Config) (tls.ConnectionState, error) {
+	errs := make(chan error, 1)
+	go func() {
+		sc, err := l.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer func() { _ = sc.Close() }()
+		s := tls.Server(sc, server)
+		if err := s.Handshake(); err != nil {
+			errs <- err
+			return
+		}
+		// The client reads the session ticket before the byte
+		_, err = s.Write([]byte{1})
+		errs <- err
+	}()
+
+	cc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer func() { _ = cc.Close() }()
+	c := tls.Client(cc, client)
+	if err := c.Handshake(); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if _, err := c.Read(make([]byte, 1)); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return c.ConnectionState(), <-errs
+}
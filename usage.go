@@ -0,0 +1,64 @@
+package benchmark
+
+import (
+	"runtime"
+	"time"
+)
+
+// Usage is the CPU time and heap allocations consumed by a process since it started
+type Usage struct {
+	UserTime   time.Duration `json:"user_time"`
+	SystemTime time.Duration `json:"system_time"`
+	Mallocs    uint64        `json:"mallocs"`
+	TotalAlloc uint64        `json:"total_alloc"`
+}
+
+// ReadUsage returns the resources consumed by the current process. CPU time is only
+// available on unix platforms and is zero elsewhere.
+func ReadUsage() Usage {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	user, system := cpuTime()
+	return Usage{
+		UserTime:   user,
+		SystemTime: system,
+		Mallocs:    m.Mallocs,
+		TotalAlloc: m.TotalAlloc,
+	}
+}
+
+// CPUTime returns the total user and system CPU time
+func (u Usage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// Sub returns the resources consumed between o and u
+func (u Usage) Sub(o Usage) Usage {
+	return Usage{
+		UserTime:   u.UserTime - o.UserTime,
+		SystemTime: u.SystemTime - o.SystemTime,
+		Mallocs:    u.Mallocs - o.Mallocs,
+		TotalAlloc: u.TotalAlloc - o.TotalAlloc,
+	}
+}
+
+// Footprint is the memory and goroutines held by a process at a point in time
+type Footprint struct {
+	Goroutines int    `json:"goroutines"`
+	HeapInuse  uint64 `json:"heap_inuse"`
+	// RSS is the resident set size of the process, only available on linux and zero elsewhere
+	RSS uint64 `json:"rss"`
+}
+
+// ReadFootprint runs a garbage collection, so only live objects are counted, and returns the
+// footprint of the current process.
+func ReadFootprint() Footprint {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Footprint{
+		Goroutines: runtime.NumGoroutine(),
+		HeapInuse:  m.HeapInuse,
+		RSS:        residentSetSize(),
+	}
+}
@@ -0,0 +1,24 @@
+package benchmark
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+func residentSetSize() uint64 {
+	// The second field of statm is the number of resident pages
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := bytes.Fields(b)
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
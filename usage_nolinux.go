@@ -0,0 +1,7 @@
+//go:build !linux
+
+package benchmark
+
+func residentSetSize() uint64 {
+	return 0
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package benchmark
+
+import "time"
+
+func cpuTime() (user, system time.Duration) {
+	return 0, 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package benchmark
+
+import (
+	"syscall"
+	"time"
+)
+
+func cpuTime() (user, system time.Duration) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano())
+}
@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-duh. DO NOT EDIT.
+// source: v1/route_guide.proto
+
+package v1
+
+import (
+	bytes "bytes"
+	context "context"
+	fmt "fmt"
+	duh_go "github.com/duh-rpc/duh-go"
+	proto "google.golang.org/protobuf/proto"
+	http "net/http"
+)
+
+const (
+	RouteGuideGetFeaturePath = "/v1/routeGuide.getFeature"
+)
+
+// RouteGuideDUHServer is the server API for the RouteGuide service served over DUH-RPC.
+//
+// DUH-RPC is a request/reply protocol, so the following streaming methods are not included:
+// ListFeatures
+// RecordRoute
+// RouteChat
+type RouteGuideDUHServer interface {
+	// A simple RPC.
+	//
+	// Obtains the feature at a given position.
+	//
+	// A feature with an empty name is returned if there's no feature at the given
+	// position.
+	GetFeature(context.Context, *Point) (*Feature, error)
+}
+
+// NewRouteGuideDUHHandler returns a handler which routes DUH-RPC requests to the server
+func NewRouteGuideDUHHandler(server RouteGuideDUHServer) *RouteGuideDUHHandler {
+	return &RouteGuideDUHHandler{server: server}
+}
+
+type RouteGuideDUHHandler struct {
+	server RouteGuideDUHServer
+}
+
+func (h *RouteGuideDUHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		duh_go.ReplyWithCode(w, r, duh_go.CodeBadRequest, nil,
+			fmt.Sprintf("http method '%s' not allowed; only POST", r.Method))
+		return
+	}
+
+	// No need for fancy routers, a switch case is performant and simple.
+	switch r.URL.Path {
+	case RouteGuideGetFeaturePath:
+		h.handleGetFeature(w, r)
+		return
+	}
+	duh_go.ReplyWithCode(w, r, duh_go.CodeNotImplemented, nil, "no such method; "+r.URL.Path)
+}
+
+func (h *RouteGuideDUHHandler) handleGetFeature(w http.ResponseWriter, r *http.Request) {
+	var req Point
+	if err := duh_go.ReadRequest(r, &req); err != nil {
+		duh_go.ReplyError(w, r, err)
+		return
+	}
+	resp, err := h.server.GetFeature(r.Context(), &req)
+	if err != nil {
+		duh_go.ReplyError(w, r, err)
+		return
+	}
+	duh_go.Reply(w, r, duh_go.CodeOK, resp)
+}
+
+type RouteGuideDUHClientConfig struct {
+	// The address of the server, for example 'http://localhost:8080'
+	Endpoint string
+
+	// (Optional) The client used to make requests. Defaults to http.DefaultClient
+	Client *http.Client
+
+	// (Optional) Prepare is called with every request before it is sent. If it returns an error
+	// the request is not sent and the error is returned to the caller.
+	Prepare func(context.Context, *http.Request) error
+}
+
+// RouteGuideDUHClient is a client for the RouteGuide service served over DUH-RPC
+type RouteGuideDUHClient struct {
+	*duh_go.Client
+	endpoint string
+	prepare  func(context.Context, *http.Request) error
+}
+
+func NewRouteGuideDUHClient(conf RouteGuideDUHClientConfig) *RouteGuideDUHClient {
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+	return &RouteGuideDUHClient{
+		Client:   &duh_go.Client{Client: conf.Client},
+		endpoint: conf.Endpoint,
+		prepare:  conf.Prepare,
+	}
+}
+
+// A simple RPC.
+//
+// Obtains the feature at a given position.
+//
+// A feature with an empty name is returned if there's no feature at the given
+// position.
+func (c *RouteGuideDUHClient) GetFeature(ctx context.Context, req *Point, resp *Feature) error {
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return duh_go.NewClientError(fmt.Errorf("while marshaling request payload: %w", err), nil)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+RouteGuideGetFeaturePath, bytes.NewReader(payload))
+	if err != nil {
+		return duh_go.NewClientError(err, nil)
+	}
+	r.Header.Set("Content-Type", duh_go.ContentTypeProtoBuf)
+
+	if c.prepare != nil {
+		if err := c.prepare(ctx, r); err != nil {
+			return err
+		}
+	}
+	return c.Do(r, resp)
+}
@@ -0,0 +1,318 @@
+package benchmark_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	window = flag.Bool("window", false, "run BenchmarkWindow, which benchmarks large requests, replies "+
+		"and streams with every combination of the -window-* settings for each HTTP/2 transport")
+	windowStream = flag.String("window-stream", "0,65535,1048576,4194304", "comma separated initial stream "+
+		"flow control windows (0 is the default)")
+	windowConn = flag.String("window-conn", "0,16777216", "comma separated initial connection flow control "+
+		"windows (0 is the default)")
+	windowFrame = flag.String("window-frame", "0,1048576", "comma separated max frame sizes for H2C and "+
+		"HTTPS (0 is the default)")
+	windowSchedulers = flag.String("window-schedulers", "priority,random", "comma separated write schedulers "+
+		"for H2C and HTTPS")
+	windowRequest = flag.Int("window-request", 1<<20, "size in bytes of the request sent to GetFeature in "+
+		"BenchmarkWindow")
+	windowReply       = flag.Int("window-reply", 1<<20, "size in bytes of the reply to GetFeature in BenchmarkWindow")
+	windowStreamReply = flag.Int("window-stream-reply", 16<<10, "size in bytes of each feature streamed by "+
+		"ListFeatures in BenchmarkWindow")
+)
+
+// everywhere is a rectangle which contains every feature of the RouteGuideService
+var everywhere = &pb.Rectangle{
+	Lo: &pb.Point{Latitude: -900000000, Longitude: -1800000000},
+	Hi: &pb.Point{Latitude: 900000000, Longitude: 1800000000},
+}
+
+// windowClient calls a RouteGuideService served with an HTTP2Tuning
+type windowClient struct {
+	getFeature func(context.Context, *pb.Point) error
+	// listFeatures returns the number of features streamed, it is nil if the transport can not stream
+	listFeatures func(context.Context) (int, error)
+}
+
+// windowTransports serve the service on the listener with the tuning, and return a client
+// tuned the same way
+var windowTransports = map[string]func(tb testing.TB, l net.Listener, svc *server.RouteGuideService,
+	conf *benchmark.TLSConfig, t benchmark.HTTP2Tuning) windowClient{
+	"GRPC":  windowGRPC,
+	"HTTP2": windowH2C,
+	"HTTPS": windowHTTPS,
+}
+
+// BenchmarkWindow calls each HTTP/2 transport with every combination of its flow control window,
+// frame size and write scheduler settings. Small windows are a known limit on the throughput of
+// HTTP/2, so each combination sends a large request and fetches a large reply, and gRPC also
+// streams features.
+//
+// http2.Transport always advertises the same windows, so for H2C and HTTPS the windows only limit
+// the request bodies read by the server. Those transports sweep the windows with the large request
+// alone, and the frame size and write scheduler with the large reply.
+func BenchmarkWindow(b *testing.B) {
+	if !*window {
+		b.Skip("pass -window to benchmark the flow control settings of each HTTP/2 transport")
+	}
+	var streams, conns, frames []int
+	for _, f := range []struct {
+		name  string
+		value string
+		to    *[]int
+	}{
+		{name: "window-stream", value: *windowStream, to: &streams},
+		{name: "window-conn", value: *windowConn, to: &conns},
+		{name: "window-frame", value: *windowFrame, to: &frames},
+	} {
+		v, err := parseInts(f.name, f.value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		*f.to = v
+	}
+	schedulers := strings.Split(*windowSchedulers, ",")
+
+	var conf benchmark.TLSConfig
+	if err := benchmark.SetupTLS(&conf); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, transport := range []string{"GRPC", "HTTP2", "HTTPS"} {
+		var runs []windowRun
+		for _, stream := range streams {
+			for _, conn := range conns {
+				t := benchmark.HTTP2Tuning{StreamWindow: int32(stream), ConnWindow: int32(conn)}
+				name := fmt.Sprintf("Stream=%d,Conn=%d", t.StreamWindow, t.ConnWindow)
+				// gRPC has fixed frame sizes and its own write scheduler, and its windows apply to
+				// both the client and the server
+				if transport == "GRPC" {
+					runs = append(runs, windowRun{name: name, tuning: t, request: true, reply: true})
+					continue
+				}
+				// The frame size limits the request frames the server reads
+				for _, frame := range frames {
+					t.MaxFrameSize = uint32(frame)
+					runs = append(runs, windowRun{name: fmt.Sprintf("%s,Frame=%d", name, frame), tuning: t,
+						request: true})
+				}
+			}
+		}
+		if transport != "GRPC" {
+			for _, frame := range frames {
+				for _, scheduler := range schedulers {
+					t := benchmark.HTTP2Tuning{
+						MaxFrameSize:   uint32(frame),
+						WriteScheduler: strings.TrimSpace(scheduler),
+					}
+					if err := t.Validate(); err != nil {
+						b.Fatal(err)
+					}
+					runs = append(runs, windowRun{name: fmt.Sprintf("Frame=%d,Scheduler=%s", t.MaxFrameSize,
+						t.WriteScheduler), tuning: t, reply: true})
+				}
+			}
+		}
+
+		for _, run := range runs {
+			b.Run(transport+"/"+run.name, func(b *testing.B) {
+				l, err := net.Listen("tcp", "localhost:0")
+				if err != nil {
+					b.Fatal(err)
+				}
+				svc := server.NewRouteGuideServer()
+				client := windowTransports[transport](b, l, svc, &conf, run.tuning)
+				runWindow(b, svc, client, run)
+			})
+		}
+	}
+}
+
+// windowRun is a tuning of a transport, and the scenarios the tuning applies to
+type windowRun struct {
+	name   string
+	tuning benchmark.HTTP2Tuning
+	// request runs the large request scenario
+	request bool
+	// reply runs the large reply and streaming scenarios
+	reply bool
+}
+
+// largePoint returns knownPoint with an unknown field of n bytes, which the server reads and
+// ignores. RouteGuide has no unary call with a large request, so this simulates one.
+func largePoint(n int) *pb.Point {
+	point := proto.Clone(knownPoint).(*pb.Point)
+	var raw []byte
+	raw = protowire.AppendTag(raw, 1000, protowire.BytesType)
+	raw = protowire.AppendBytes(raw, make([]byte, n))
+	point.ProtoReflect().SetUnknown(raw)
+	return point
+}
+
+// runWindow runs the scenarios of the run with the client
+func runWindow(b *testing.B, svc *server.RouteGuideService, client windowClient, run windowRun) {
+	ctx := context.Background()
+	if run.request {
+		b.Run("LargeRequest", func(b *testing.B) {
+			point := largePoint(*windowRequest)
+			if err := client.getFeature(ctx, point); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(*windowRequest))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := client.getFeature(ctx, point); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	if !run.reply {
+		return
+	}
+	b.Run("LargeReply", func(b *testing.B) {
+		svc.SetReplySize(*windowReply)
+		defer svc.SetReplySize(0)
+		if err := client.getFeature(ctx, knownPoint); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(*windowReply))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err := client.getFeature(ctx, knownPoint); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	if client.listFeatures == nil {
+		return
+	}
+	b.Run("ListFeatures", func(b *testing.B) {
+		svc.SetReplySize(*windowStreamReply)
+		defer svc.SetReplySize(0)
+		count, err := client.listFeatures(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(count * *windowStreamReply))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if _, err := client.listFeatures(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func windowGRPC(tb testing.TB, l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig,
+	t benchmark.HTTP2Tuning) windowClient {
+	srv := grpc.NewServer(t.GRPCServerOptions()...)
+	pb.RegisterRouteGuideServer(srv, svc)
+	go func() { _ = srv.Serve(l) }()
+	tb.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(l.Addr().String(), append(t.GRPCDialOptions(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = conn.Close() })
+	client := pb.NewRouteGuideClient(conn)
+
+	return windowClient{
+		getFeature: func(ctx context.Context, point *pb.Point) error {
+			_, err := client.GetFeature(ctx, point)
+			return err
+		},
+		listFeatures: func(ctx context.Context) (int, error) {
+			stream, err := client.ListFeatures(ctx, everywhere)
+			if err != nil {
+				return 0, err
+			}
+			var count int
+			for {
+				if _, err := stream.Recv(); err != nil {
+					if errors.Is(err, io.EOF) {
+						return count, nil
+					}
+					return count, err
+				}
+				count++
+			}
+		},
+	}
+}
+
+func windowH2C(tb testing.TB, l net.Listener, svc *server.RouteGuideService, _ *benchmark.TLSConfig,
+	t benchmark.HTTP2Tuning) windowClient {
+	h2s := &http2.Server{}
+	t.Server(h2s)
+	srv := &http.Server{Handler: h2c.NewHandler(benchmark.NewHTTPHandler(svc), h2s)}
+	go func() { _ = srv.Serve(l) }()
+	tb.Cleanup(func() { _ = srv.Close() })
+
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	t.Transport(transport)
+	tb.Cleanup(transport.CloseIdleConnections)
+	return windowHTTPClient(&http.Client{Transport: transport}, "http://"+l.Addr().String())
+}
+
+func windowHTTPS(tb testing.TB, l net.Listener, svc *server.RouteGuideService, conf *benchmark.TLSConfig,
+	t benchmark.HTTP2Tuning) windowClient {
+	h2s := &http2.Server{}
+	t.Server(h2s)
+	// ConfigureServer modifies the TLS config, which is shared by every configuration
+	srv := &http.Server{Handler: benchmark.NewHTTPHandler(svc), TLSConfig: conf.ServerTLS.Clone()}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		tb.Fatal(err)
+	}
+	go func() { _ = srv.ServeTLS(l, "", "") }()
+	tb.Cleanup(func() { _ = srv.Close() })
+
+	transport := &http2.Transport{TLSClientConfig: conf.ClientTLS}
+	t.Transport(transport)
+	tb.Cleanup(transport.CloseIdleConnections)
+	return windowHTTPClient(&http.Client{Transport: transport}, "https://"+l.Addr().String())
+}
+
+// windowHTTPClient returns a pooled DUH client, which asks for protobuf replies as gRPC uses
+func windowHTTPClient(hc *http.Client, endpoint string) windowClient {
+	client := benchmark.NewClient(hc, endpoint, benchmark.WithPooling())
+	return windowClient{
+		getFeature: func(ctx context.Context, point *pb.Point) error {
+			var resp pb.Feature
+			return client.GetFeature(ctx, point, &resp)
+		},
+	}
+}
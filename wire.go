@@ -0,0 +1,228 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	"github.com/duh-rpc/duh-go-benchmarks/v1"
+	duhv1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// wireRequest is the part of a DUH-RPC request needed to serve it. The fields are slices so
+// HTTP stacks which expose the request as []byte can provide them without copying.
+type wireRequest struct {
+	method      []byte
+	path        []byte
+	contentType []byte
+	accept      []byte
+	timeout     []byte
+	body        []byte
+}
+
+// wireReply is the reply to a wireRequest
+type wireReply struct {
+	code        int
+	contentType string
+	body        []byte
+}
+
+// wireHandler serves the RouteGuide service over DUH-RPC for HTTP stacks which do not implement
+// http.Handler, and for handlers which marshal with a Codec. It follows the same rules as Handler
+// and the generated router.
+type wireHandler struct {
+	service duhService
+	codec   Codec
+}
+
+func newWireHandler(service *server.RouteGuideService, codec Codec) wireHandler {
+	return wireHandler{service: duhService{service: service}, codec: codec}
+}
+
+// serve handles the request and appends the body of the reply to dst
+func (h wireHandler) serve(ctx context.Context, r wireRequest, dst []byte) wireReply {
+	// If the client provided a timeout, the server should stop working on the request
+	// once the client is no longer waiting for the reply.
+	if len(r.timeout) != 0 {
+		timeout, err := time.ParseDuration(string(r.timeout))
+		if err != nil {
+			return h.replyWithCode(r, dst, duh.CodeBadRequest, "invalid '"+HeaderTimeout+"' header; "+err.Error())
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Used by the benchmarks to establish a connection before the benchmark begins
+	if string(r.path) == "/v1/say.hello" {
+		return wireReply{code: duh.CodeOK, contentType: duh.ContentOctetStream, body: append(dst, "Hello!"...)}
+	}
+
+	if string(r.method) != http.MethodPost {
+		return h.replyWithCode(r, dst, duh.CodeBadRequest,
+			fmt.Sprintf("http method '%s' not allowed; only POST", r.method))
+	}
+	if string(r.path) != v1.RouteGuideGetFeaturePath {
+		return h.replyWithCode(r, dst, duh.CodeNotImplemented, "no such method; "+string(r.path))
+	}
+
+	var req v1.Point
+	if err := h.unmarshal(r.contentType, r.body, &req); err != nil {
+		return h.replyError(r, dst, err)
+	}
+	resp, err := h.service.GetFeature(ctx, &req)
+	if err != nil {
+		return h.replyError(r, dst, err)
+	}
+	return h.reply(r, dst, duh.CodeOK, resp)
+}
+
+// unmarshal un-marshals the body according to the content type as duh.ReadRequest() does
+func (h wireHandler) unmarshal(contentType, body []byte, m proto.Message) error {
+	switch mimeTypeBytes(contentType) {
+	case "", "*/*", "application/*", duh.ContentTypeJSON:
+		if err := protojson.Unmarshal(body, m); err != nil {
+			return duh.NewServiceError(duh.CodeContentTypeError, err, nil)
+		}
+		return nil
+	case duh.ContentTypeProtoBuf:
+		if err := h.codec.Unmarshal(body, m); err != nil {
+			return duh.NewServiceError(duh.CodeContentTypeError, err, nil)
+		}
+		return nil
+	}
+	return duh.NewServiceError(duh.CodeContentTypeError,
+		fmt.Errorf("Content-Type header '%s' is invalid format or unrecognized content type", contentType), nil)
+}
+
+// replyError replies with the code and message of the error as duh.ReplyError() does
+func (h wireHandler) replyError(r wireRequest, dst []byte, err error) wireReply {
+	var re duh.Error
+	if errors.As(err, &re) {
+		return h.reply(r, dst, re.Code(), re.ProtoMessage())
+	}
+	return h.replyWithCode(r, dst, duh.CodeInternalError, err.Error())
+}
+
+func (h wireHandler) replyWithCode(r wireRequest, dst []byte, code int, msg string) wireReply {
+	return h.reply(r, dst, code, &duhv1.Reply{Code: int32(code), Message: msg})
+}
+
+// reply marshals the message in the format the client accepts as duh.Reply() does
+func (h wireHandler) reply(r wireRequest, dst []byte, code int, m proto.Message) wireReply {
+	switch mimeTypeBytes(r.accept) {
+	case "", "*/*", "application/*", duh.ContentTypeJSON:
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return h.replyWithCode(r, dst, duh.CodeInternalError, err.Error())
+		}
+		return wireReply{code: code, contentType: duh.ContentTypeJSON, body: append(dst, b...)}
+	case duh.ContentTypeProtoBuf:
+		b, err := h.codec.MarshalAppend(dst, m)
+		if err != nil {
+			return h.replyWithCode(r, dst, duh.CodeInternalError, err.Error())
+		}
+		return wireReply{code: code, contentType: duh.ContentTypeProtoBuf, body: b}
+	}
+	msg := fmt.Sprintf("Accept header '%s' is invalid format or unrecognized content type, only [%s] are "+
+		"supported by this method", mimeTypeBytes(r.accept), strings.Join(duh.SupportedMimeTypes, ","))
+	// Reply in JSON, as the client accepts neither format
+	r.accept = nil
+	return h.replyWithCode(r, dst, duh.CodeContentTypeError, msg)
+}
+
+// mimeType ignores multiple mime types separated by comma ',' or mime type parameters separated by
+// semicolon ';'
+func mimeType(s string) string {
+	return strings.TrimSpace(strings.ToLower(duh.TrimSuffix(s, ";,")))
+}
+
+// mimeTypeBytes is mimeType for header values provided as []byte, which avoids converting the
+// value to a string when it is exactly one of the supported mime types
+func mimeTypeBytes(b []byte) string {
+	switch string(b) {
+	case "":
+		return ""
+	case duh.ContentTypeProtoBuf:
+		return duh.ContentTypeProtoBuf
+	case duh.ContentTypeJSON:
+		return duh.ContentTypeJSON
+	}
+	return mimeType(string(b))
+}
+
+// decodeReply un-marshals a DUH-RPC reply into out with the codec, or returns the error the reply
+// contains as duh.Client.Do() does. The http.Request and http.Response duh-go requires to build
+// errors are only created when the reply is an error, so clients which do not use net/http can
+// share it.
+func decodeReply(codec Codec, method string, u *url.URL, status int, contentType string, body []byte, out proto.Message) error {
+	if status == duh.CodeOK {
+		switch mimeType(contentType) {
+		case duh.ContentTypeProtoBuf:
+			if err := codec.Unmarshal(body, out); err != nil {
+				return duh.NewServiceError(duh.CodeClientError,
+					fmt.Errorf("while parsing response body '%s': %w", body, err), nil)
+			}
+			return nil
+		case duh.ContentTypeJSON:
+			if err := protojson.Unmarshal(body, out); err != nil {
+				return duh.NewServiceError(duh.CodeClientError,
+					fmt.Errorf("while parsing response body '%s': %w", body, err), nil)
+			}
+			return nil
+		}
+	}
+
+	req := &http.Request{Method: method, URL: u}
+	resp := &http.Response{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))}
+	if !duh.IsReplyCode(status) && status != CodeDeadlineExceeded {
+		return duh.NewInfraError(req, resp, body)
+	}
+
+	var r duhv1.Reply
+	var err error
+	switch mimeType(contentType) {
+	case duh.ContentTypeProtoBuf:
+		err = proto.Unmarshal(body, &r)
+	case duh.ContentTypeJSON:
+		err = protojson.Unmarshal(body, &r)
+	default:
+		return duh.NewInfraError(req, resp, body)
+	}
+	if err != nil {
+		return duh.NewInfraError(req, resp, body)
+	}
+	return duh.NewReplyError(req, resp, &r)
+}
+
+// wireTimeout returns the value of the HeaderTimeout header for the context, or an error if
+// the deadline has already passed
+func wireTimeout(ctx context.Context) (string, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", nil
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return "", duh.NewServiceError(CodeDeadlineExceeded, context.DeadlineExceeded, nil)
+	}
+	return timeout.String(), nil
+}
+
+// transportError returns the error of a call the transport failed to complete. Calls which failed
+// because their deadline expired return CodeDeadlineExceeded, so callers can tell them apart from
+// other failures.
+func transportError(err error, deadlineExceeded bool, details map[string]string) error {
+	if deadlineExceeded {
+		return duh.NewServiceError(CodeDeadlineExceeded, err, details)
+	}
+	return duh.NewClientError(err, details)
+}
@@ -0,0 +1,95 @@
+package benchmark_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	hzclient "github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/duh-rpc/duh-go"
+	benchmark "github.com/duh-rpc/duh-go-benchmarks"
+	"github.com/duh-rpc/duh-go-benchmarks/server"
+	pb "github.com/duh-rpc/duh-go-benchmarks/v1"
+	"github.com/valyala/fasthttp"
+)
+
+// TestWireTransports calls each server with each client, the fasthttp and Hertz servers must
+// reply as the net/http server does, and every client must understand each of them
+func TestWireTransports(t *testing.T) {
+	type client interface {
+		GetFeature(context.Context, *pb.Point, *pb.Feature) error
+	}
+	clients := map[string]func(t *testing.T, endpoint string) client{
+		"HTTP1": func(t *testing.T, endpoint string) client {
+			return benchmark.NewClient(&http.Client{}, endpoint, benchmark.WithPooling())
+		},
+		"FastHTTP": func(t *testing.T, endpoint string) client {
+			c, err := benchmark.NewFastHTTPClient(&fasthttp.Client{}, endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return c
+		},
+		"Hertz": func(t *testing.T, endpoint string) client {
+			hc, err := hzclient.NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := benchmark.NewHertzClient(hc, endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return c
+		},
+	}
+
+	for _, srv := range []string{"HTTP1", "FastHTTP", "Hertz"} {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc := server.NewRouteGuideServer()
+		stop := servers[srv](l, svc, nil)
+		endpoint := "http://" + l.Addr().String()
+
+		for _, name := range []string{"HTTP1", "FastHTTP", "Hertz"} {
+			t.Run(srv+"/"+name, func(t *testing.T) {
+				c := clients[name](t, endpoint)
+				ctx := context.Background()
+
+				var resp pb.Feature
+				if err := c.GetFeature(ctx, knownPoint, &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Name == "" {
+					t.Errorf("expected the name of the feature at '%v'", knownPoint)
+				}
+
+				svc.SetErrorMode(server.ErrorModeNotFoundWithDetails)
+				err := c.GetFeature(ctx, unknownPoint, &resp)
+				svc.SetErrorMode(server.ErrorModeNone)
+				var de duh.Error
+				if !errors.As(err, &de) || de.Code() != duh.CodeNotFound {
+					t.Errorf("expected error with code '%d'; got '%v'", duh.CodeNotFound, err)
+				}
+
+				// The server must stop working on the call once the timeout the client sent expires
+				svc.SetLatency(time.Second)
+				canceled := svc.CanceledCount()
+				dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+				err = c.GetFeature(dctx, knownPoint, &resp)
+				cancel()
+				svc.SetLatency(0)
+				if !isDeadlineExceeded(dctx, err) {
+					t.Errorf("expected deadline exceeded; got '%v'", err)
+				}
+				waitForCanceled(t, svc, canceled+1)
+			})
+		}
+		stop()
+		_ = l.Close()
+	}
+}